@@ -1,21 +1,24 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
+	_ "time/tzdata" // the timezone database might be missing on the host
 
 	"github.com/h44z/dntroomloggpro-go/pkg"
 
 	"github.com/sirupsen/logrus"
 )
 
-type publisher interface {
-	Publish(settings *pkg.SettingsData, channels []*pkg.ChannelData, isOnline bool) error
-}
-
 // You need to create a influx db before using this tool:
 /*
 $ influx (-username xxx -password yyy)
@@ -25,103 +28,272 @@ $ influx (-username xxx -password yyy)
 func main() {
 	logrus.SetLevel(logrus.DebugLevel)
 
-	rCfg := pkg.NewRoomLoggConfig()
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML config file, environment variables override its values")
+	flag.Parse()
 
-	r := pkg.NewRoomLogg(rCfg)
-	if err := r.Open(); err != nil {
-		logrus.Fatal("[MAIN] Unable to initialize RoomLogg!", err)
+	cfg, err := pkg.LoadConfig(*configFile)
+	if err != nil {
+		logrus.Fatalf("[MAIN] Invalid configuration: %v", err)
 	}
-	defer r.Close()
+	rCfg := &cfg.RoomLogg
+	loc, _ := rCfg.Location() // validated by LoadConfig
 
-	rest, mqtt, influx := features()
+	stations := openStations(rCfg)
 
-	var publishers []publisher
+	publishers := newPublisherSet(stations)
+	if err := publishers.apply(cfg); err != nil {
+		logrus.Fatalf("[MAIN] Unable to initialize publishers: %v", err)
+	}
 
-	if rest {
-		sCfg := pkg.NewRestConfig()
-		s, err := pkg.NewServer(sCfg)
-		if err != nil {
-			logrus.Fatalf("Unable to initialize WebServer: %v", err)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ctx := handleShutdown()
+
+	logrus.Infof("[MAIN] Starting %d station(s) in %v (%d pub)...", len(stations), time.Duration(rCfg.PollingRate)*time.Second, publishers.len())
+
+	// Poll each station separately
+	wg := sync.WaitGroup{}
+	rates := make([]chan time.Duration, len(stations))
+	for i, r := range stations {
+		trigger := make(chan struct{}, 1)
+		r.AddConnectionHandler(connectionHandler(publishers, trigger))
+		if rCfg.MonitorInterval > 0 {
+			if err := r.StartMonitor(time.Duration(rCfg.MonitorInterval) * time.Second); err != nil {
+				logrus.Warnf("[MAIN] Unable to start device monitor: %v", err)
+			}
+		}
+
+		if err := r.ApplyAlarms(); err != nil {
+			logrus.Errorf("[MAIN] Failed to apply alarms to RoomLogg %s: %v", stationName(r), err)
+		}
+
+		if rCfg.TimeSyncInterval > 0 {
+			wg.Add(1)
+			go func(r *pkg.RoomLogg) {
+				defer wg.Done()
+				syncClock(ctx, r, rCfg, loc)
+			}(r)
+		}
+
+		rates[i] = make(chan time.Duration, 1)
+		wg.Add(1)
+		go func(r *pkg.RoomLogg, rate <-chan time.Duration) {
+			defer wg.Done()
+			poll(ctx, r, time.Duration(rCfg.PollingRate)*time.Second, publishers, trigger, rate)
+		}(r, rates[i])
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		reload(ctx, *configFile, rCfg, hup, publishers, rates)
+	}()
+
+	wg.Wait()
+	shutdown(stations, publishers)
+}
+
+// handleShutdown returns a context that is cancelled on SIGINT or SIGTERM. A second signal exits immediately.
+func handleShutdown() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		s := <-sig
+		logrus.Infof("[MAIN] Received %v, shutting down...", s)
+		cancel()
+
+		s = <-sig
+		logrus.Fatalf("[MAIN] Received %v again, exiting without cleanup!", s)
+	}()
+
+	return ctx
+}
+
+// shutdown publishes the offline state of all stations, closes the publishers and releases the stations. Polling
+// must be stopped already.
+func shutdown(stations []*pkg.RoomLogg, publishers *publisherSet) {
+	for _, r := range stations {
+		r.StopMonitor() // no more connection events
+	}
+
+	for _, r := range stations {
+		publishers.publish(pkg.NewReading(time.Now(), r.ID(), nil, nil, false))
+	}
+	publishers.close() // flushes the queued readings
+
+	for _, r := range stations {
+		r.Close()
+	}
+
+	logrus.Infof("[MAIN] Shutdown completed!")
+}
+
+// connectionHandler marks the station offline as soon as it gets disconnected and triggers a poll once the
+// connection is back.
+func connectionHandler(publishers *publisherSet, trigger chan<- struct{}) pkg.ConnectionHandler {
+	return func(event pkg.ConnectionEvent) {
+		if event.State == pkg.StateConnected {
+			select {
+			case trigger <- struct{}{}:
+			default: // poll already pending
+			}
+			return
 		}
-		s.SetRoomLogInstance(r)
-		go s.Run() // start webserver
 
-		publishers = append(publishers, s)
+		publishers.publish(pkg.NewReading(event.Time, event.Station, nil, nil, false))
 	}
+}
 
-	if mqtt {
-		mCfg := pkg.NewMqttConfig()
-		p, err := pkg.NewMqttPublisher(mCfg)
+// openStations opens the configured stations. If no stations are configured, the only attached station is used.
+func openStations(cfg *pkg.RoomLoggConfig) []*pkg.RoomLogg {
+	if len(cfg.Stations) == 0 {
+		r, err := pkg.NewRoomLogg(cfg)
 		if err != nil {
-			logrus.Fatalf("[MAIN] Unable to initialize MQTT publisher: %v", err)
+			logrus.Fatalf("[MAIN] Invalid device configuration: %v", err)
+		}
+		if err := r.Open(); err != nil {
+			logrus.Fatal("[MAIN] Unable to initialize RoomLogg!", err)
 		}
-		defer p.Close()
+		return []*pkg.RoomLogg{r}
+	}
 
-		publishers = append(publishers, p)
+	if !cfg.Emulate {
+		devices, err := pkg.ListDevices(cfg.Backend)
+		if err != nil {
+			logrus.Warnf("[MAIN] Unable to list RoomLogg devices: %v", err)
+		}
+		for _, device := range devices {
+			logrus.Infof("[MAIN] Found RoomLogg device %s", device)
+		}
 	}
 
-	if influx {
-		iCfg := pkg.NewInfluxConfig()
-		i := pkg.NewInfluxLogger(iCfg)
-		defer i.Close()
+	ids := make([]string, 0, len(cfg.Stations))
+	for id := range cfg.Stations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
 
-		publishers = append(publishers, i)
+	stations := make([]*pkg.RoomLogg, 0, len(ids))
+	for _, id := range ids {
+		r, err := pkg.NewRoomLoggStation(cfg, id, cfg.Stations[id])
+		if err != nil {
+			logrus.Fatalf("[MAIN] Invalid device for station %s: %v", id, err)
+		}
+		if err := r.Open(); err != nil {
+			logrus.Fatalf("[MAIN] Unable to initialize RoomLogg station %s: %v", id, err)
+		}
+		stations = append(stations, r)
 	}
 
-	logrus.Infof("[MAIN] Starting in %v (%d pub)...", time.Duration(rCfg.PollingRate)*time.Second, len(publishers))
+	return stations
+}
 
+// poll polls the station at the given rate, a new rate received on rates is applied to the running ticker.
+// A running poll is completed once the context is done.
+func poll(ctx context.Context, r *pkg.RoomLogg, rate time.Duration, publishers *publisherSet, trigger <-chan struct{},
+	rates <-chan time.Duration) {
 	// Start ticker
-	ticker := time.NewTicker(time.Duration(rCfg.PollingRate) * time.Second)
+	ticker := time.NewTicker(rate)
 	defer ticker.Stop()
 	for {
 		select {
+		case <-ctx.Done():
+			logrus.Debugf("[MAIN] Stopped polling %s", stationName(r))
+			return
+		case rate = <-rates:
+			ticker.Reset(rate)
+			logrus.Infof("[MAIN] Polling %s every %v", stationName(r), rate)
+		case <-trigger:
+			logrus.Debugf("[MAIN] Connection to %s restored, polling now", stationName(r))
+			pollStation(r, rate, publishers)
 		case <-ticker.C:
-			isOnline := true
-			settings, err := r.FetchSettings()
-			if err != nil {
-				logrus.Errorf("[MAIN] Lost connection to RoomLogg: %v", err)
-				_ = r.Reconnect()
-				isOnline = false
-			}
-			channelData, err := r.FetchCurrentData()
-			if err != nil {
-				logrus.Errorf("[MAIN] Lost connection to GCM: %v", err)
-				_ = r.Reconnect()
-				isOnline = false
-			}
+			pollStation(r, rate, publishers)
+		}
+	}
+}
 
-			logMsg := make([]string, len(channelData))
-			for i, ch := range channelData {
-				logMsg[i] = fmt.Sprintf("CH %d: %0.1f°C/%0.0f%% ", ch.Number, ch.Temperature, ch.Humidity)
-			}
-			logrus.Infof("[MAIN] Fetched: %s", strings.Join(logMsg, "; "))
-
-			for i, p := range publishers {
-				err := p.Publish(settings, channelData, isOnline)
-				if err != nil {
-					logrus.Errorf("[MAIN] Failed to publish: %v", err)
-				}
-				logrus.Debugf("[MAIN] Published #%d", i)
-			}
+func pollStation(r *pkg.RoomLogg, rate time.Duration, publishers *publisherSet) {
+	name := stationName(r)
+
+	// A poll must not run into the next tick
+	ctx, cancel := context.WithTimeout(context.Background(), rate)
+	defer cancel()
 
-			logrus.Info("[MAIN] Tick completed!")
+	start := time.Now()
+	settings, err := r.FetchSettingsContext(ctx)
+	if err != nil {
+		// Skip the current data, the station is reconnected once per tick at most
+		logrus.Errorf("[MAIN] Lost connection to RoomLogg %s: %v", name, err)
+		reconnect(r)
+		pkg.DefaultMetrics.ObservePoll(r.ID(), time.Since(start), err)
+		publishers.publish(pkg.NewReading(time.Now(), r.ID(), nil, nil, false))
+		return
+	}
+	channelData, err := r.FetchCurrentDataContext(ctx)
+	if err != nil {
+		logrus.Errorf("[MAIN] Lost connection to RoomLogg %s: %v", name, err)
+		reconnect(r)
+	}
+	pkg.DefaultMetrics.ObservePoll(r.ID(), time.Since(start), err)
+	isOnline := err == nil
+
+	logMsg := make([]string, len(channelData))
+	for i, ch := range channelData {
+		label := strconv.Itoa(ch.Number)
+		if ch.Info != nil && ch.Info.Name != "" {
+			label = fmt.Sprintf("%d (%s)", ch.Number, ch.Info.Name)
 		}
+		logMsg[i] = fmt.Sprintf("CH %s: %0.1f%s/%0.0f%% ", label, ch.Temperature, ch.Unit.Symbol(), ch.Humidity)
 	}
+	logrus.Infof("[MAIN] Fetched %s: %s", name, strings.Join(logMsg, "; "))
+
+	publishers.publish(pkg.NewReading(time.Now(), r.ID(), settings, channelData, isOnline))
+
+	logrus.Infof("[MAIN] Tick completed %s!", name)
 }
 
-func features() (rest, mqtt, influx bool) {
-	rest = true
-	mqtt = true
-	influx = true
+// syncClock syncs the station clock right away, after each sync interval and shortly after each DST transition.
+// A failed sync is retried after the polling rate. It returns once the context is done, a running sync is completed.
+func syncClock(ctx context.Context, r *pkg.RoomLogg, cfg *pkg.RoomLoggConfig, loc *time.Location) {
+	name := stationName(r)
+	interval := time.Duration(cfg.TimeSyncInterval) * time.Second
+	for {
+		syncCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.PollingRate)*time.Second)
+		err := r.SyncTimeContext(syncCtx)
+		cancel()
+
+		next := pkg.NextTimeSync(loc, time.Now(), interval)
+		if err != nil {
+			logrus.Errorf("[MAIN] Failed to sync clock of RoomLogg %s: %v", name, err)
+			if retry := time.Now().Add(time.Duration(cfg.PollingRate) * time.Second); retry.Before(next) {
+				next = retry
+			}
+		} else {
+			logrus.Infof("[MAIN] Synced clock of RoomLogg %s (%s), next sync at %s", name, loc,
+				next.In(loc).Format(time.RFC3339))
+		}
 
-	if val, err := strconv.ParseBool(os.Getenv("ENABLE_REST")); err == nil && !val {
-		rest = false
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
 	}
-	if val, err := strconv.ParseBool(os.Getenv("ENABLE_MQTT")); err == nil && !val {
-		mqtt = false
+}
+
+func reconnect(r *pkg.RoomLogg) {
+	if err := r.Reconnect(); err != nil {
+		logrus.Errorf("[MAIN] Failed to reconnect to RoomLogg %s: %v", stationName(r), err)
 	}
-	if val, err := strconv.ParseBool(os.Getenv("ENABLE_INFLUX")); err == nil && !val {
-		influx = false
+}
+
+func stationName(r *pkg.RoomLogg) string {
+	if r.ID() == "" {
+		return "default"
 	}
-	return
+	return r.ID()
 }
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/h44z/dntroomloggpro-go/pkg"
+)
+
+// recordingPublisher records the published readings, each publish fails while fail is set.
+type recordingPublisher struct {
+	start error // returned by Start
+
+	mux      sync.Mutex
+	fail     error
+	readings []*pkg.Reading
+	closed   bool
+}
+
+func (p *recordingPublisher) Start(_ context.Context) error  { return p.start }
+func (p *recordingPublisher) Health(_ context.Context) error { return nil }
+
+func (p *recordingPublisher) Publish(_ context.Context, reading *pkg.Reading) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.fail != nil {
+		return p.fail
+	}
+	p.readings = append(p.readings, reading)
+
+	return nil
+}
+
+func (p *recordingPublisher) Close() error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.closed = true
+
+	return nil
+}
+
+func (p *recordingPublisher) setFail(err error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.fail = err
+}
+
+func (p *recordingPublisher) result() ([]*pkg.Reading, bool) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	return append([]*pkg.Reading(nil), p.readings...), p.closed
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); !condition(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+	}
+}
+
+func TestShutdown_OfflineAfterSpool(t *testing.T) {
+	r, err := pkg.NewRoomLoggStation(&pkg.RoomLoggConfig{PollingRate: 60, Emulate: true}, "house", "")
+	if err != nil {
+		t.Fatalf("NewRoomLoggStation() error = %v", err)
+	}
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	p := &recordingPublisher{fail: errors.New("broker down")}
+	opts := pkg.QueueOptions{Size: 10, Timeout: time.Second, Overflow: pkg.OverflowDropOldest,
+		Spool: pkg.SpoolOptions{Dir: t.TempDir(), MaxSize: 1 << 20, MaxAge: time.Hour}}
+	queue, err := pkg.NewPublisherQueue("fake", p, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishers := newPublisherSet([]*pkg.RoomLogg{r})
+	publishers.running["fake"] = &runningPublisher{publisher: p, queue: queue}
+
+	publishers.publish(pkg.NewReading(time.Now(), r.ID(), nil, nil, true))
+	waitFor(t, "spooled reading", func() bool { return queue.Spooled() == 1 })
+	p.setFail(nil) // the broker is back, but the spool is not replayed yet
+
+	shutdown([]*pkg.RoomLogg{r}, publishers)
+
+	readings, closed := p.result()
+	if len(readings) != 2 || !readings[0].Online || readings[1].Online || readings[1].Station != "house" {
+		t.Errorf("shutdown() published = %+v, want the spooled reading and the offline state of house", readings)
+	}
+	if !closed {
+		t.Errorf("shutdown() did not close the publisher")
+	}
+	if got := queue.Spooled(); got != 0 {
+		t.Errorf("shutdown() spooled = %v, want %v", got, 0)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/h44z/dntroomloggpro-go/pkg"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	configWatchInterval   = 5 * time.Second  // how often the config file is checked for changes
+	publisherFlushTimeout = 30 * time.Second // how long queued readings may take once a publisher is closed
+)
+
+// publisherSet holds the enabled publishers of the registry, each with its own queue. The publishers are replaced on
+// config reloads while the stations are polled.
+type publisherSet struct {
+	mux      sync.RWMutex
+	stations []*pkg.RoomLogg // stations for direct requests, e.g. through the REST server
+	running  map[string]*runningPublisher
+}
+
+type runningPublisher struct {
+	publisher pkg.Publisher
+	queue     *pkg.PublisherQueue
+	cfg       any // config section the publisher was built with
+}
+
+func newPublisherSet(stations []*pkg.RoomLogg) *publisherSet {
+	return &publisherSet{stations: stations, running: make(map[string]*runningPublisher)}
+}
+
+// apply starts the enabled publishers whose config changed and closes the disabled ones. Unchanged publishers keep
+// running. All publishers are applied, the first error is returned. apply must not be called concurrently, the lock
+// is only held to swap publishers, so that publishing never waits for a publisher being started or flushed.
+func (s *publisherSet) apply(cfg *pkg.Config) error {
+	var firstErr error
+	for _, name := range pkg.RegisteredPublishers() {
+		registration, _ := pkg.LookupPublisher(name)
+		if err := s.applyPublisher(name, registration, cfg); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return firstErr
+}
+
+func (s *publisherSet) applyPublisher(name string, registration pkg.PublisherRegistration, cfg *pkg.Config) error {
+	s.mux.RLock()
+	running, ok := s.running[name]
+	s.mux.RUnlock()
+
+	enabled := registration.Enabled(cfg)
+	section := registration.Config(cfg)
+	if ok == enabled && (!ok || reflect.DeepEqual(running.cfg, section)) {
+		return nil // unchanged
+	}
+
+	if ok { // the old publisher might hold resources the new one needs, e.g. the listen address
+		s.mux.Lock()
+		delete(s.running, name)
+		s.mux.Unlock()
+		closePublisher(name, running)
+	}
+	if !enabled {
+		return nil
+	}
+
+	p, err := registration.New(cfg, s.stations)
+	if err != nil {
+		return err
+	}
+	if err := p.Start(context.Background()); err != nil {
+		_ = p.Close()
+		return err
+	}
+	if err := p.Health(context.Background()); err != nil {
+		logrus.Warnf("[MAIN] Publisher %s is not healthy: %v", name, err)
+	}
+
+	opts := pkg.DefaultQueueOptions()
+	if registration.Queue != nil {
+		opts = registration.Queue(cfg)
+	}
+	queue, err := pkg.NewPublisherQueue(name, p, opts)
+	if err != nil {
+		_ = p.Close()
+		return err
+	}
+	s.mux.Lock()
+	s.running[name] = &runningPublisher{publisher: p, queue: queue, cfg: section}
+	s.mux.Unlock()
+	logrus.Infof("[MAIN] Started publisher %s (queue %d, timeout %v, %s)", name, opts.Size, opts.Timeout, opts.Overflow)
+	if spooled := queue.Spooled(); spooled > 0 {
+		logrus.Infof("[MAIN] Publisher %s replays %d spooled readings", name, spooled)
+	}
+
+	return nil
+}
+
+// closePublisher publishes the queued readings and closes the publisher.
+func closePublisher(name string, running *runningPublisher) {
+	ctx, cancel := context.WithTimeout(context.Background(), publisherFlushTimeout)
+	defer cancel()
+	if err := running.queue.Close(ctx); err != nil {
+		logrus.Errorf("[MAIN] %v", err)
+	}
+
+	if err := running.publisher.Close(); err != nil {
+		logrus.Errorf("[MAIN] Failed to close publisher %s: %v", name, err)
+	}
+	logrus.Infof("[MAIN] Closed publisher %s", name)
+}
+
+// close flushes and closes all publishers in parallel.
+func (s *publisherSet) close() {
+	s.mux.Lock()
+	running := s.running
+	s.running = make(map[string]*runningPublisher)
+	s.mux.Unlock()
+
+	wg := sync.WaitGroup{}
+	for name, r := range running {
+		wg.Add(1)
+		go func(name string, r *runningPublisher) {
+			defer wg.Done()
+			closePublisher(name, r)
+		}(name, r)
+	}
+	wg.Wait()
+}
+
+// publish queues the reading for all running publishers, it never waits for a publisher.
+func (s *publisherSet) publish(reading *pkg.Reading) {
+	pkg.DefaultMetrics.ObserveReading(reading)
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	for _, r := range s.running {
+		r.queue.Enqueue(reading)
+	}
+}
+
+// len returns the number of running publishers.
+func (s *publisherSet) len() int {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return len(s.running)
+}
+
+// reload applies the config file whenever a signal arrives on hup or the file changes. The stations stay open:
+// changed publishers are rebuilt and a new polling rate is sent to the running pollers. Other station settings need a
+// restart. An invalid config is logged and the running config is kept. It returns once the context is done.
+func reload(ctx context.Context, path string, stationCfg *pkg.RoomLoggConfig, hup <-chan os.Signal,
+	publishers *publisherSet, rates []chan time.Duration) {
+	changed := make(chan struct{}, 1)
+	if path != "" {
+		go watchFile(ctx, path, configWatchInterval, changed)
+	}
+
+	pollingRate := stationCfg.PollingRate
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			logrus.Infof("[MAIN] Received SIGHUP, reloading configuration...")
+		case <-changed:
+			logrus.Infof("[MAIN] Config file %s changed, reloading configuration...", path)
+		}
+
+		cfg, err := pkg.LoadConfig(path)
+		if err != nil {
+			logrus.Errorf("[MAIN] Invalid configuration, keeping the running one: %v", err)
+			continue
+		}
+
+		if err := publishers.apply(cfg); err != nil {
+			logrus.Errorf("[MAIN] Unable to apply publisher configuration: %v", err)
+		}
+
+		if cfg.RoomLogg.PollingRate != pollingRate {
+			pollingRate = cfg.RoomLogg.PollingRate
+			for _, rate := range rates {
+				select {
+				case <-rate: // replace a rate that was not picked up yet
+				default:
+				}
+				rate <- time.Duration(pollingRate) * time.Second
+			}
+		}
+
+		if restartRequired(stationCfg, &cfg.RoomLogg) {
+			logrus.Warnf("[MAIN] Station configuration changed, restart the logger to apply it")
+		}
+
+		logrus.Infof("[MAIN] Configuration reloaded (%d pub)", publishers.len())
+	}
+}
+
+// restartRequired reports whether the station configs differ in other values than the polling rate.
+func restartRequired(running, loaded *pkg.RoomLoggConfig) bool {
+	cmp := *loaded
+	cmp.PollingRate = running.PollingRate
+
+	return !reflect.DeepEqual(*running, cmp)
+}
+
+// watchFile signals on changed whenever the modification time or the size of the file change, until the context is
+// done.
+func watchFile(ctx context.Context, path string, interval time.Duration, changed chan<- struct{}) {
+	last, _ := os.Stat(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue // the file might be replaced right now
+		}
+		if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		last = info
+
+		select {
+		case changed <- struct{}{}:
+		default: // reload already pending
+		}
+	}
+}
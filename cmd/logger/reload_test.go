@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/h44z/dntroomloggpro-go/pkg"
+)
+
+func TestRestartRequired(t *testing.T) {
+	stations := map[string]string{"house": "1-1"}
+	running := &pkg.RoomLoggConfig{PollingRate: 60, TimeZone: "Europe/Vienna", Stations: stations}
+	tests := []struct {
+		name   string
+		loaded pkg.RoomLoggConfig
+		want   bool
+	}{
+		{"Unchanged", pkg.RoomLoggConfig{PollingRate: 60, TimeZone: "Europe/Vienna", Stations: stations}, false},
+		{"PollingRate", pkg.RoomLoggConfig{PollingRate: 30, TimeZone: "Europe/Vienna", Stations: stations}, false},
+		{"TimeZone", pkg.RoomLoggConfig{PollingRate: 60, TimeZone: "UTC", Stations: stations}, true},
+		{"Stations", pkg.RoomLoggConfig{PollingRate: 60, TimeZone: "Europe/Vienna",
+			Stations: map[string]string{"house": "1-2"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := restartRequired(running, &tt.loaded); got != tt.want {
+				t.Errorf("restartRequired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeRegistration registers recording publishers that follow the MQTT toggle and topic of the config.
+func fakeRegistration(created *[]*recordingPublisher, newErr, startErr error) pkg.PublisherRegistration {
+	return pkg.PublisherRegistration{
+		Enabled: func(cfg *pkg.Config) bool { return cfg.Features.Mqtt },
+		Config:  func(cfg *pkg.Config) any { return cfg.Mqtt.Topic },
+		New: func(_ *pkg.Config, _ []*pkg.RoomLogg) (pkg.Publisher, error) {
+			if newErr != nil {
+				return nil, newErr
+			}
+			p := &recordingPublisher{start: startErr}
+			*created = append(*created, p)
+			return p, nil
+		},
+	}
+}
+
+func TestPublisherSet_applyPublisher(t *testing.T) {
+	failed := errors.New("failed")
+	tests := []struct {
+		name        string
+		running     string // topic of the running publisher, empty if none runs
+		enabled     bool
+		topic       string
+		newErr      error
+		startErr    error
+		wantErr     bool
+		wantClosed  bool // the running publisher was closed
+		wantCreated int
+		wantRunning bool
+	}{
+		{name: "Start", enabled: true, topic: "rl", wantCreated: 1, wantRunning: true},
+		{name: "Disabled", topic: "rl"},
+		{name: "Unchanged", running: "rl", enabled: true, topic: "rl", wantRunning: true},
+		{name: "Changed", running: "rl", enabled: true, topic: "rl7", wantClosed: true, wantCreated: 1, wantRunning: true},
+		{name: "Stop", running: "rl", topic: "rl", wantClosed: true},
+		{name: "NewFails", running: "rl", enabled: true, topic: "rl7", newErr: failed, wantErr: true, wantClosed: true},
+		{name: "StartFails", enabled: true, topic: "rl", startErr: failed, wantErr: true, wantCreated: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newPublisherSet(nil)
+			old := &recordingPublisher{}
+			if tt.running != "" {
+				queue, err := pkg.NewPublisherQueue("fake", old, pkg.DefaultQueueOptions())
+				if err != nil {
+					t.Fatal(err)
+				}
+				s.running["fake"] = &runningPublisher{publisher: old, queue: queue, cfg: tt.running}
+			}
+			defer s.close()
+
+			var created []*recordingPublisher
+			cfg := &pkg.Config{Features: pkg.FeatureConfig{Mqtt: tt.enabled}, Mqtt: pkg.MqttConfig{Topic: tt.topic}}
+			err := s.applyPublisher("fake", fakeRegistration(&created, tt.newErr, tt.startErr), cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("applyPublisher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if _, closed := old.result(); closed != tt.wantClosed {
+				t.Errorf("applyPublisher() closed running publisher = %v, want %v", closed, tt.wantClosed)
+			}
+			if len(created) != tt.wantCreated {
+				t.Fatalf("applyPublisher() created %d publishers, want %d", len(created), tt.wantCreated)
+			}
+			if tt.startErr != nil {
+				if _, closed := created[0].result(); !closed {
+					t.Errorf("applyPublisher() did not close the publisher that failed to start")
+				}
+			}
+
+			running, ok := s.running["fake"]
+			if ok != tt.wantRunning {
+				t.Fatalf("applyPublisher() running = %v, want %v", ok, tt.wantRunning)
+			}
+			if ok && running.cfg != tt.topic {
+				t.Errorf("applyPublisher() running config = %v, want %v", running.cfg, tt.topic)
+			}
+		})
+	}
+}
+
+func TestPublisherSet_apply(t *testing.T) {
+	s := newPublisherSet(nil)
+	p := &recordingPublisher{}
+	queue, err := pkg.NewPublisherQueue("mqtt", p, pkg.DefaultQueueOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.running["mqtt"] = &runningPublisher{publisher: p, queue: queue, cfg: pkg.MqttConfig{}}
+
+	if err := s.apply(&pkg.Config{}); err != nil { // all publishers disabled
+		t.Fatalf("apply() error = %v", err)
+	}
+
+	if _, closed := p.result(); !closed {
+		t.Errorf("apply() did not close the disabled publisher")
+	}
+	if got := s.len(); got != 0 {
+		t.Errorf("apply() running publishers = %v, want %v", got, 0)
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "roomlogg.yaml")
+	if err := os.WriteFile(path, []byte("roomlogg: {polling_rate: 60}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changed := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watchFile(ctx, path, 10*time.Millisecond, changed)
+	}()
+
+	select {
+	case <-changed:
+		t.Fatalf("watchFile() signalled an unchanged file")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(path, []byte("roomlogg: {polling_rate: 120}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("watchFile() did not signal the changed file")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("watchFile() did not return once the context was done")
+	}
+}
@@ -11,12 +11,26 @@ import (
 func main() {
 	//logrus.SetLevel(logrus.TraceLevel)
 
-	r := pkg.NewRoomLogg(pkg.NewRoomLoggConfig())
+	cfg, err := pkg.NewRoomLoggConfig()
+	if err != nil {
+		logrus.Fatalf("Invalid configuration: %v", err)
+	}
+
+	r, err := pkg.NewRoomLogg(cfg)
+	if err != nil {
+		logrus.Fatalf("Invalid device configuration: %v", err)
+	}
 	if err := r.Open(); err != nil {
 		logrus.Fatal("Unable to initialize DNT RoomLogg PRO!")
 	}
 	defer r.Close()
 
+	if cfg.GetTime { // the get-time command is experimental
+		if drift, err := r.FetchClockDrift(); err == nil {
+			logrus.Infof("Station clock: %s, drift: %v", drift.StationTime.Format(time.RFC3339), drift.Drift.Round(time.Second))
+		}
+	}
+
 	for {
 		time.Sleep(30 * time.Second)
 
@@ -33,7 +47,7 @@ func main() {
 
 		logrus.Infof("----------------------------------------")
 		for _, channel := range channelData {
-			logrus.Infof("ChannelData %d:\t %1.1f °C,\t %1.0f %%", channel.Number, channel.Temperature, channel.Humidity)
+			logrus.Infof("ChannelData %d:\t %1.1f %s,\t %1.0f %%", channel.Number, channel.Temperature, channel.Unit.Symbol(), channel.Humidity)
 		}
 		for _, channel := range calibrationData {
 			logrus.Infof("CalibrationData %d:\t %1.1f °C,\t %1.0f %%", channel.Channel, channel.Temperature, channel.Humidity)
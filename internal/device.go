@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DeviceInfo describes the location of a USB device.
+type DeviceInfo struct {
+	Bus     int
+	Address int
+	Path    []int // Port numbers, starting at the root hub
+}
+
+// PortPath returns the physical port path in the same notation that linux uses in sysfs, e.g. 1-2.3 for a device
+// connected to port 3 of a hub on port 2 of bus 1. In contrast to the address, the port path is stable across
+// reconnects and reboots.
+func (d DeviceInfo) PortPath() string {
+	ports := make([]string, len(d.Path))
+	for i, port := range d.Path {
+		ports[i] = strconv.Itoa(port)
+	}
+	return fmt.Sprintf("%d-%s", d.Bus, strings.Join(ports, "."))
+}
+
+func (d DeviceInfo) String() string {
+	return fmt.Sprintf("%03d.%03d (port %s)", d.Bus, d.Address, d.PortPath())
+}
+
+// DeviceSelector selects one USB device, either by bus and address or by port path.
+// An empty selector matches all devices.
+type DeviceSelector struct {
+	Bus      int
+	Address  int
+	PortPath string
+}
+
+// ParseDeviceSelector parses a device selector. Supported formats are bus.address (e.g. 001.004 or 1.4)
+// and port paths (e.g. 1-2 or 1-2.3). An empty string selects any device.
+func ParseDeviceSelector(s string) (DeviceSelector, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "":
+		return DeviceSelector{}, nil
+	case strings.Contains(s, "-"):
+		parts := strings.Split(s, "-")
+		if len(parts) != 2 || !isNumeric(parts[0]) {
+			return DeviceSelector{}, fmt.Errorf("invalid port path %q", s)
+		}
+		for _, port := range strings.Split(parts[1], ".") {
+			if !isNumeric(port) {
+				return DeviceSelector{}, fmt.Errorf("invalid port path %q", s)
+			}
+		}
+		return DeviceSelector{PortPath: s}, nil
+	case strings.Contains(s, "."):
+		parts := strings.Split(s, ".")
+		if len(parts) != 2 {
+			return DeviceSelector{}, fmt.Errorf("invalid bus address %q", s)
+		}
+		bus, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return DeviceSelector{}, fmt.Errorf("invalid bus number in %q: %w", s, err)
+		}
+		address, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return DeviceSelector{}, fmt.Errorf("invalid device address in %q: %w", s, err)
+		}
+		return DeviceSelector{Bus: bus, Address: address}, nil
+	}
+
+	return DeviceSelector{}, fmt.Errorf("invalid device selector %q, use bus.address or a port path", s)
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// Matches checks if the device matches the selector.
+func (s DeviceSelector) Matches(device DeviceInfo) bool {
+	switch {
+	case s.PortPath != "":
+		return s.PortPath == device.PortPath()
+	case s.Bus != 0:
+		return s.Bus == device.Bus && s.Address == device.Address
+	}
+	return true
+}
+
+func (s DeviceSelector) String() string {
+	switch {
+	case s.PortPath != "":
+		return "port " + s.PortPath
+	case s.Bus != 0:
+		return fmt.Sprintf("bus %03d.%03d", s.Bus, s.Address)
+	}
+	return "any device"
+}
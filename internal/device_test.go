@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDeviceSelector(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    string
+		want    DeviceSelector
+		wantErr bool
+	}{
+		{name: "Empty", args: "", want: DeviceSelector{}},
+		{name: "BusAddress", args: "001.004", want: DeviceSelector{Bus: 1, Address: 4}},
+		{name: "BusAddressShort", args: "3.12", want: DeviceSelector{Bus: 3, Address: 12}},
+		{name: "PortPath", args: "1-2", want: DeviceSelector{PortPath: "1-2"}},
+		{name: "PortPathHub", args: "1-2.3", want: DeviceSelector{PortPath: "1-2.3"}},
+		{name: "InvalidPortPath", args: "1-2..3", wantErr: true},
+		{name: "InvalidBusAddress", args: "1.x", wantErr: true},
+		{name: "Invalid", args: "garage", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDeviceSelector(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseDeviceSelector() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDeviceSelector() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeviceSelector_Matches(t *testing.T) {
+	device := DeviceInfo{Bus: 1, Address: 7, Path: []int{2, 3}}
+	tests := []struct {
+		name     string
+		selector DeviceSelector
+		want     bool
+	}{
+		{name: "Any", selector: DeviceSelector{}, want: true},
+		{name: "BusAddress", selector: DeviceSelector{Bus: 1, Address: 7}, want: true},
+		{name: "OtherAddress", selector: DeviceSelector{Bus: 1, Address: 8}, want: false},
+		{name: "PortPath", selector: DeviceSelector{PortPath: "1-2.3"}, want: true},
+		{name: "OtherPortPath", selector: DeviceSelector{PortPath: "1-2"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.selector.Matches(device); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
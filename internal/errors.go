@@ -0,0 +1,18 @@
+package internal
+
+import "errors"
+
+var (
+	// ErrDeviceNotFound is returned if no matching DNT RoomLogg PRO device is attached.
+	ErrDeviceNotFound = errors.New("no DNT RoomLogg PRO device found")
+	// ErrMultipleDevices is returned if more than one device matches and no device was selected.
+	ErrMultipleDevices = errors.New("found multiple DNT RoomLogg PRO devices")
+	// ErrDisconnected is returned if the connection is not open or the device is gone.
+	ErrDisconnected = errors.New("DNT RoomLogg PRO device disconnected")
+	// ErrTimeout is returned if the device did not answer in time.
+	ErrTimeout = errors.New("DNT RoomLogg PRO device timed out")
+	// ErrIncompleteFrame is returned if a response frame is missing its start or end bytes.
+	ErrIncompleteFrame = errors.New("incomplete response frame")
+	// ErrUnexpectedResponse is returned if a complete response frame does not have the expected payload size.
+	ErrUnexpectedResponse = errors.New("response does not match the command")
+)
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+)
+
+var (
+	MessageStart = []byte{0x7b}
+	MessageEnd   = []byte{0x40, 0x7d}
+)
+
+// MaxResponseSize is the upper limit for a response frame that spans multiple USB packets.
+const MaxResponseSize = 16 * 1024
+
+// buildFrame wraps the command and its payload in the message start and end bytes.
+func buildFrame(command byte, payload []byte) []byte {
+	var body = make([]byte, 0, 4+len(payload)) // 4 for command and start/end bytes
+	body = append(body, MessageStart...)
+	body = append(body, command)
+	body = append(body, payload...)
+	body = append(body, MessageEnd...)
+
+	return body
+}
+
+// readFrame reads packets until the message end bytes are found or maxSize bytes have been read.
+// A response that is longer than one USB packet is split up into multiple packets by the device.
+func readFrame(read func(buf []byte) (int, error), packetSize, maxSize int) ([]byte, error) {
+	frame := make([]byte, 0, packetSize)
+	for {
+		// Buffer large enough for 1 USB packet (64 bytes) from in-endpoint.
+		packet := make([]byte, packetSize)
+		// numReadBytes might be smaller than the buffer size. numReadBytes might be greater than zero even if err is not nil.
+		numReadBytes, err := read(packet)
+		frame = append(frame, packet[:numReadBytes]...)
+		if err != nil {
+			if len(frame) > 0 {
+				return nil, fmt.Errorf("%w: failed to read raw bytes: %v, read %d bytes", ErrIncompleteFrame, err, len(frame))
+			}
+			return nil, fmt.Errorf("failed to read raw bytes: %w, read %d bytes", err, len(frame))
+		}
+		if numReadBytes == 0 {
+			return nil, fmt.Errorf("%w: empty packet after %d bytes", ErrIncompleteFrame, len(frame))
+		}
+
+		if len(frame) > 0 && frame[0] != MessageStart[0] {
+			return nil, fmt.Errorf("%w: unexpected start byte 0x%02x", ErrIncompleteFrame, frame[0])
+		}
+		if bytes.Contains(frame, MessageEnd) {
+			return frame, nil
+		}
+		if len(frame) >= maxSize {
+			return nil, fmt.Errorf("%w: no message end within %d bytes", ErrIncompleteFrame, maxSize)
+		}
+	}
+}
+
+func GetMessagePayload(raw []byte) ([]byte, error) {
+	if raw == nil || len(raw) < 3 {
+		return nil, fmt.Errorf("%w: invalid raw message size", ErrIncompleteFrame)
+	}
+
+	startIndex := 1 // First byte can be dismissed, its 0x7b (MessageStart)
+	endIndex := bytes.Index(raw, MessageEnd)
+
+	if endIndex == -1 {
+		return nil, fmt.Errorf("%w: unable to find message end", ErrIncompleteFrame)
+	}
+
+	return raw[startIndex:endIndex], nil
+}
+
+// GetMessagePayloadOfSize returns the payload of a response that must have exactly size bytes. The message end
+// bytes are expected right after the payload, so end bytes that are part of the payload are not mistaken for the
+// end of the message. Trailing bytes after the message end (USB packet padding) are ignored.
+func GetMessagePayloadOfSize(raw []byte, size int) ([]byte, error) {
+	if len(raw) < 1 || raw[0] != MessageStart[0] {
+		return nil, fmt.Errorf("%w: missing message start", ErrIncompleteFrame)
+	}
+
+	endIndex := 1 + size
+	if len(raw) >= endIndex+len(MessageEnd) && bytes.Equal(raw[endIndex:endIndex+len(MessageEnd)], MessageEnd) {
+		return raw[1:endIndex], nil
+	}
+	if actual := bytes.Index(raw[1:], MessageEnd); actual != -1 {
+		return nil, fmt.Errorf("%w: payload has %d bytes, want %d", ErrUnexpectedResponse, actual, size)
+	}
+
+	return nil, fmt.Errorf("%w: unable to find message end after %d payload bytes", ErrIncompleteFrame, size)
+}
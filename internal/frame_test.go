@@ -0,0 +1,217 @@
+package internal
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGetMessagePayload(t *testing.T) {
+	type args struct {
+		raw []byte
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name: "Valid1",
+			args: args{
+				raw: []byte{0x7b, 0x01, 0x02, 0x03, 0x40, 0x7d, 0x99, 0xff},
+			},
+			want:    []byte{0x01, 0x02, 0x03},
+			wantErr: false,
+		},
+		{
+			name: "Valid2",
+			args: args{
+				raw: []byte{0x7b, 0x01, 0x02, 0x03, 0x40, 0x7d},
+			},
+			want:    []byte{0x01, 0x02, 0x03},
+			wantErr: false,
+		},
+		{
+			name: "Valid3",
+			args: args{
+				raw: []byte{0x7b, 0x40, 0x7d},
+			},
+			want:    []byte{},
+			wantErr: false,
+		},
+		{
+			name: "Invalid1",
+			args: args{
+				raw: []byte{0x7b, 0x00, 0x7d},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Invalid2",
+			args: args{
+				raw: []byte{0x7b},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Invalid3",
+			args: args{
+				raw: nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetMessagePayload(tt.args.raw)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetMessagePayload() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetMessagePayload() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMessagePayloadOfSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     []byte
+		size    int
+		want    []byte
+		wantErr error
+	}{
+		{"exact size", []byte{0x7b, 0x01, 0x02, 0x03, 0x40, 0x7d}, 3, []byte{0x01, 0x02, 0x03}, nil},
+		{"padding", []byte{0x7b, 0x01, 0x40, 0x7d, 0x00, 0x00}, 1, []byte{0x01}, nil},
+		{"end bytes in payload", []byte{0x7b, 0x40, 0x7d, 0x03, 0x40, 0x7d}, 3, []byte{0x40, 0x7d, 0x03}, nil},
+		{"shorter payload", []byte{0x7b, 0x01, 0x40, 0x7d, 0x00, 0x00}, 3, nil, ErrUnexpectedResponse},
+		{"longer payload", []byte{0x7b, 0x01, 0x02, 0x03, 0x40, 0x7d}, 1, nil, ErrUnexpectedResponse},
+		{"missing end", []byte{0x7b, 0x01, 0x02, 0x03}, 3, nil, ErrIncompleteFrame},
+		{"missing start", []byte{0x01, 0x02, 0x40, 0x7d}, 2, nil, ErrIncompleteFrame},
+		{"empty", nil, 0, nil, ErrIncompleteFrame},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetMessagePayloadOfSize(tt.raw, tt.size)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("GetMessagePayloadOfSize() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetMessagePayloadOfSize() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func packetReader(packets [][]byte) func(buf []byte) (int, error) {
+	index := 0
+	return func(buf []byte) (int, error) {
+		if index >= len(packets) {
+			return 0, errors.New("timeout")
+		}
+		n := copy(buf, packets[index])
+		index++
+		return n, nil
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	type args struct {
+		packets [][]byte
+		maxSize int
+	}
+	tests := []struct {
+		name       string
+		args       args
+		want       []byte
+		wantErr    bool
+		incomplete bool
+	}{
+		{
+			name: "SinglePacket",
+			args: args{
+				packets: [][]byte{{0x7b, 0x01, 0x02, 0x40, 0x7d, 0x00, 0x00, 0x00}},
+				maxSize: 64,
+			},
+			want: []byte{0x7b, 0x01, 0x02, 0x40, 0x7d, 0x00, 0x00, 0x00},
+		},
+		{
+			name: "MultiPacket",
+			args: args{
+				packets: [][]byte{{0x7b, 0x01, 0x02, 0x03}, {0x04, 0x05, 0x40, 0x7d}},
+				maxSize: 64,
+			},
+			want: []byte{0x7b, 0x01, 0x02, 0x03, 0x04, 0x05, 0x40, 0x7d},
+		},
+		{
+			name: "SplitTerminator",
+			args: args{
+				packets: [][]byte{{0x7b, 0x01, 0x02, 0x40}, {0x7d, 0x00, 0x00, 0x00}},
+				maxSize: 64,
+			},
+			want: []byte{0x7b, 0x01, 0x02, 0x40, 0x7d, 0x00, 0x00, 0x00},
+		},
+		{
+			name: "Truncated",
+			args: args{
+				packets: [][]byte{{0x7b, 0x01, 0x02, 0x03}},
+				maxSize: 64,
+			},
+			want:       nil,
+			wantErr:    true,
+			incomplete: true,
+		},
+		{
+			name: "SizeLimit",
+			args: args{
+				packets: [][]byte{{0x7b, 0x01, 0x02, 0x03}, {0x04, 0x05, 0x06, 0x07}, {0x40, 0x7d, 0x00, 0x00}},
+				maxSize: 8,
+			},
+			want:       nil,
+			wantErr:    true,
+			incomplete: true,
+		},
+		{
+			name: "BadStart",
+			args: args{
+				packets: [][]byte{{0x00, 0x01, 0x40, 0x7d}},
+				maxSize: 64,
+			},
+			want:       nil,
+			wantErr:    true,
+			incomplete: true,
+		},
+		{
+			name: "NoData",
+			args: args{
+				packets: nil,
+				maxSize: 64,
+			},
+			want:       nil,
+			wantErr:    true,
+			incomplete: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readFrame(packetReader(tt.args.packets), 8, tt.args.maxSize)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("readFrame() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if errors.Is(err, ErrIncompleteFrame) != tt.incomplete {
+				t.Errorf("readFrame() error = %v, incomplete %v", err, tt.incomplete)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readFrame() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
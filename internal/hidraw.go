@@ -0,0 +1,315 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// HidrawPacketSize is the size of one HID report of the DNT RoomLogg PRO, it matches the USB endpoint packet size.
+const HidrawPacketSize = 64
+
+var (
+	hidrawClassDir = "/sys/class/hidraw" // sysfs directory that lists all hidraw devices
+	hidrawDevDir   = "/dev"              // directory of the hidraw device nodes
+)
+
+// hidrawFile is the opened hidraw device node.
+type hidrawFile interface {
+	io.ReadWriteCloser
+	SetDeadline(t time.Time) error
+}
+
+// HidrawDevice is a DNT RoomLogg PRO that is accessible through a Linux hidraw device node.
+type HidrawDevice struct {
+	Node string // e.g. /dev/hidraw0
+	Info DeviceInfo
+}
+
+// HidrawConnection talks to the DNT RoomLogg PRO through the Linux hidraw interface. In contrast to the
+// UsbConnection, it does not need libusb and cgo.
+type HidrawConnection struct {
+	selector DeviceSelector
+
+	file  hidrawFile
+	trace *TraceRecorder // records all exchanges if set
+}
+
+// NewHidrawConnection creates a hidraw connection to the DNT RoomLogg PRO device that matches the given selector.
+func NewHidrawConnection(selector DeviceSelector) *HidrawConnection {
+	c := &HidrawConnection{selector: selector}
+
+	return c
+}
+
+func (c *HidrawConnection) Open() error {
+	devices, err := ListHidrawDevices()
+	if err != nil {
+		logrus.Errorf("Failed to list hidraw devices: %v", err)
+		return err
+	}
+
+	var matches []HidrawDevice
+	for _, device := range devices {
+		if c.selector.Matches(device.Info) {
+			matches = append(matches, device)
+		}
+	}
+	switch {
+	case len(matches) == 0:
+		logrus.Errorf("No DNT RoomLogg PRO hidraw device found (%s).", c.selector)
+		return fmt.Errorf("%w (%s)", ErrDeviceNotFound, c.selector)
+	case len(matches) > 1:
+		logrus.Errorf("Found multiple DNT RoomLogg PRO devices, select one device by bus/address or port path.")
+		return fmt.Errorf("%w, select one device by bus/address or port path", ErrMultipleDevices)
+	}
+
+	logrus.Infof("Found DNT RoomLogg PRO on USB bus %03d.%03d (port %s) at %s", matches[0].Info.Bus,
+		matches[0].Info.Address, matches[0].Info.PortPath(), matches[0].Node)
+
+	file, err := os.OpenFile(matches[0].Node, os.O_RDWR, 0)
+	if err != nil {
+		logrus.Errorf("Failed to open %s: %v", matches[0].Node, err)
+		return err
+	}
+	c.file = file
+
+	return nil
+}
+
+// WatchPresence reports whenever the device of this connection is plugged in or removed.
+func (c *HidrawConnection) WatchPresence(interval time.Duration, done <-chan struct{}) <-chan bool {
+	return watchPresence(func() (bool, error) {
+		devices, err := ListHidrawDevices()
+		if err != nil {
+			return false, err
+		}
+		for _, device := range devices {
+			if c.selector.Matches(device.Info) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}, interval, done)
+}
+
+func (c *HidrawConnection) Close() {
+	if c.file != nil {
+		c.file.Close()
+		c.file = nil
+	}
+}
+
+// SetTrace enables recording of all exchanges, nil disables the recording.
+func (c *HidrawConnection) SetTrace(trace *TraceRecorder) {
+	c.trace = trace
+}
+
+// Request sends the command to the device and reads the response. The context bounds both, the write and the read.
+func (c *HidrawConnection) Request(ctx context.Context, command byte, payload []byte, noResponse ...bool) ([]byte, error) {
+	start := time.Now()
+	body := buildFrame(command, payload)
+	response, err := c.exchange(ctx, body, noResponse...)
+	c.trace.Record(start, command, body, response, len(noResponse) > 0 && noResponse[0], err)
+
+	return response, err
+}
+
+func (c *HidrawConnection) exchange(ctx context.Context, body []byte, noResponse ...bool) ([]byte, error) {
+	if c.file == nil {
+		return nil, fmt.Errorf("%w: connection is not open", ErrDisconnected)
+	}
+
+	stop := c.applyContext(ctx)
+	defer stop()
+
+	for offset := 0; offset < len(body); offset += HidrawPacketSize {
+		// The station does not use numbered reports, so each report starts with report ID 0
+		report := make([]byte, 1+HidrawPacketSize)
+		copy(report[1:], body[offset:])
+
+		logrus.Tracef("Writing raw report: %d bytes", len(report))
+		numWrittenBytes, err := c.file.Write(report)
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			logrus.Errorf("Failed to write raw bytes: %v", ctxErr)
+			return nil, fmt.Errorf("failed to write raw bytes: %w", ctxErr)
+		}
+		if err != nil || numWrittenBytes != len(report) {
+			logrus.Errorf("Failed to write raw bytes: %v. Written: %d bytes", err, numWrittenBytes)
+			return nil, fmt.Errorf("%w: failed to write raw bytes: %v, written: %d bytes", hidrawError(err), err, numWrittenBytes)
+		}
+	}
+
+	if noResponse != nil && len(noResponse) > 0 && noResponse[0] == true {
+		return nil, nil
+	}
+
+	inData, err := readFrame(c.file.Read, HidrawPacketSize, MaxResponseSize)
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		logrus.Errorf("Failed to read raw bytes: %v", ctxErr)
+		return nil, fmt.Errorf("failed to read raw bytes: %w", ctxErr)
+	}
+	if err != nil {
+		logrus.Errorf("Failed to read raw bytes: %v", err)
+		if errors.Is(err, ErrIncompleteFrame) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", hidrawError(err), err)
+	}
+	logrus.Tracef("Read %d bytes from device", len(inData))
+
+	return inData, nil
+}
+
+// applyContext applies the context deadline to the device node and interrupts pending reads and writes once the
+// context is done. The returned function must be called after the exchange.
+func (c *HidrawConnection) applyContext(ctx context.Context) func() {
+	deadline, _ := ctx.Deadline() // zero time if there is no deadline
+	if err := c.file.SetDeadline(deadline); err != nil {
+		logrus.Debugf("Failed to set hidraw deadline: %v", err)
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			_ = c.file.SetDeadline(time.Unix(1, 0)) // a deadline in the past interrupts the pending call
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped // the next exchange must not be interrupted
+	}
+}
+
+// contextError returns the context error. The deadline of the device node can expire slightly before the context
+// itself reports it, so a passed deadline counts as exceeded as well.
+func contextError(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+
+	return nil
+}
+
+// hidrawError maps a failed read or write to ErrTimeout or ErrDisconnected.
+func hidrawError(err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return ErrTimeout
+	}
+
+	return ErrDisconnected
+}
+
+// ListHidrawDevices returns all DNT RoomLogg PRO devices that are accessible through hidraw device nodes.
+func ListHidrawDevices() ([]HidrawDevice, error) {
+	entries, err := os.ReadDir(hidrawClassDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil // no hidraw support, so there are no devices
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []HidrawDevice
+	for _, entry := range entries {
+		info, ok, err := hidrawDeviceInfo(filepath.Join(hidrawClassDir, entry.Name(), "device"))
+		if err != nil {
+			logrus.Debugf("Skipping hidraw device %s: %v", entry.Name(), err)
+			continue
+		}
+		if ok {
+			devices = append(devices, HidrawDevice{Node: filepath.Join(hidrawDevDir, entry.Name()), Info: info})
+		}
+	}
+
+	return devices, nil
+}
+
+// hidrawDeviceInfo reads the USB location of a hidraw device from sysfs. The HID device directory is located
+// below the USB interface, which again is located below the USB device (e.g. .../1-2.3/1-2.3:1.0/0003:0483:5750.0001).
+// The returned flag reports if the device is a DNT RoomLogg PRO.
+func hidrawDeviceInfo(hidDir string) (DeviceInfo, bool, error) {
+	hidDir, err := filepath.EvalSymlinks(hidDir)
+	if err != nil {
+		return DeviceInfo{}, false, err
+	}
+
+	uevent, err := os.ReadFile(filepath.Join(hidDir, "uevent"))
+	if err != nil {
+		return DeviceInfo{}, false, err
+	}
+	if !isRoomLoggHidID(string(uevent)) {
+		return DeviceInfo{}, false, nil
+	}
+
+	usbDir := filepath.Dir(filepath.Dir(hidDir))
+	bus, err := readSysfsInt(filepath.Join(usbDir, "busnum"))
+	if err != nil {
+		return DeviceInfo{}, false, err
+	}
+	address, err := readSysfsInt(filepath.Join(usbDir, "devnum"))
+	if err != nil {
+		return DeviceInfo{}, false, err
+	}
+	path, err := parsePortPath(filepath.Base(usbDir))
+	if err != nil {
+		return DeviceInfo{}, false, err
+	}
+
+	return DeviceInfo{Bus: bus, Address: address, Path: path}, true, nil
+}
+
+// isRoomLoggHidID checks the HID_ID line of a uevent file, it contains bus type, vendor and product,
+// e.g. HID_ID=0003:00000483:00005750.
+func isRoomLoggHidID(uevent string) bool {
+	for _, line := range strings.Split(uevent, "\n") {
+		if strings.HasPrefix(line, "HID_ID=") {
+			return strings.EqualFold(strings.TrimPrefix(line, "HID_ID="), "0003:00000483:00005750")
+		}
+	}
+	return false
+}
+
+// parsePortPath parses the ports of a sysfs USB device name like 1-2.3.
+func parsePortPath(name string) ([]int, error) {
+	parts := strings.Split(name, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid usb device name %q", name)
+	}
+
+	var path []int
+	for _, port := range strings.Split(parts[1], ".") {
+		number, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("invalid usb device name %q: %w", name, err)
+		}
+		path = append(path, number)
+	}
+
+	return path, nil
+}
+
+func readSysfsInt(file string) (int, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(raw)))
+}
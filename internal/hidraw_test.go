@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeSysfs creates a sysfs tree with hidraw devices below dir. Each entry maps the hidraw name to the
+// USB device name and the HID_ID.
+func fakeSysfs(t *testing.T, dir string, devices map[string][2]string) {
+	t.Helper()
+
+	classDir := filepath.Join(dir, "class", "hidraw")
+	for name, device := range devices {
+		usbDir := filepath.Join(dir, "devices", "usb1", device[0])
+		hidDir := filepath.Join(usbDir, device[0]+":1.0", "0003:0483:5750.0001")
+		files := map[string]string{
+			filepath.Join(usbDir, "busnum"):      "1\n",
+			filepath.Join(usbDir, "devnum"):      "7\n",
+			filepath.Join(hidDir, "uevent"):      "DRIVER=hid-generic\nHID_ID=" + device[1] + "\nHID_NAME=test\n",
+			filepath.Join(classDir, name, "dev"): "243:0\n",
+		}
+		for file, content := range files {
+			if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Symlink(hidDir, filepath.Join(classDir, name, "device")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldClassDir, oldDevDir := hidrawClassDir, hidrawDevDir
+	hidrawClassDir, hidrawDevDir = classDir, filepath.Join(dir, "dev")
+	t.Cleanup(func() {
+		hidrawClassDir, hidrawDevDir = oldClassDir, oldDevDir
+	})
+}
+
+func TestListHidrawDevices(t *testing.T) {
+	dir := t.TempDir()
+	fakeSysfs(t, dir, map[string][2]string{
+		"hidraw0": {"1-1", "0003:0000046D:0000C52B"}, // keyboard receiver
+		"hidraw1": {"1-2.3", "0003:00000483:00005750"},
+	})
+
+	got, err := ListHidrawDevices()
+	if err != nil {
+		t.Fatalf("ListHidrawDevices() error = %v", err)
+	}
+	want := []HidrawDevice{
+		{Node: filepath.Join(dir, "dev", "hidraw1"), Info: DeviceInfo{Bus: 1, Address: 7, Path: []int{2, 3}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListHidrawDevices() got = %v, want %v", got, want)
+	}
+}
+
+func TestListHidrawDevicesNoSysfs(t *testing.T) {
+	oldClassDir := hidrawClassDir
+	hidrawClassDir = filepath.Join(t.TempDir(), "missing")
+	defer func() { hidrawClassDir = oldClassDir }()
+
+	got, err := ListHidrawDevices()
+	if err != nil || got != nil {
+		t.Errorf("ListHidrawDevices() got = %v, %v, want no devices", got, err)
+	}
+}
+
+func TestHidrawConnection_OpenNotFound(t *testing.T) {
+	fakeSysfs(t, t.TempDir(), map[string][2]string{
+		"hidraw0": {"1-1", "0003:00000483:00005750"},
+	})
+
+	c := NewHidrawConnection(DeviceSelector{PortPath: "1-4"})
+	if err := c.Open(); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("Open() error = %v, want %v", err, ErrDeviceNotFound)
+	}
+}
+
+// fakeHidrawDevice reads reports from the connection and answers each one with the next response.
+// The response is split into packets of HidrawPacketSize bytes, like the station does.
+func fakeHidrawDevice(t *testing.T, device net.Conn, responses [][]byte, reports chan<- []byte) {
+	defer close(reports)
+
+	for _, response := range responses {
+		report := make([]byte, 1+HidrawPacketSize)
+		if _, err := device.Read(report); err != nil {
+			return
+		}
+		reports <- report
+
+		if response == nil {
+			continue // the station does not answer set commands
+		}
+		for len(response)%HidrawPacketSize != 0 {
+			response = append(response, 0)
+		}
+		for offset := 0; offset < len(response); offset += HidrawPacketSize {
+			if _, err := device.Write(response[offset : offset+HidrawPacketSize]); err != nil {
+				t.Errorf("fake device write error = %v", err)
+				return
+			}
+		}
+	}
+}
+
+func TestHidrawConnection_Request(t *testing.T) {
+	longPayload := bytes.Repeat([]byte{0x11}, 100)
+	tests := []struct {
+		name       string
+		command    byte
+		payload    []byte
+		response   []byte
+		noResponse bool
+		want       []byte
+	}{
+		{"single packet", 0x03, nil, []byte{0x7b, 0x00, 0x25, 0x46, 0x40, 0x7d}, false, []byte{0x7b, 0x00, 0x25, 0x46, 0x40, 0x7d}},
+		{"multiple packets", 0x01, []byte{0x00, 0x01}, append(append([]byte{0x7b}, longPayload...), 0x40, 0x7d), false, append(append([]byte{0x7b}, longPayload...), 0x40, 0x7d)},
+		{"no response", 0x0b, []byte{0x01}, nil, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, device := net.Pipe()
+			defer host.Close()
+			defer device.Close()
+
+			reports := make(chan []byte, 1)
+			go fakeHidrawDevice(t, device, [][]byte{tt.response}, reports)
+
+			c := &HidrawConnection{file: host}
+			got, err := c.Request(context.Background(), tt.command, tt.payload, tt.noResponse)
+			if err != nil {
+				t.Fatalf("Request() error = %v", err)
+			}
+			if len(got) < len(tt.want) || !reflect.DeepEqual(got[:len(tt.want)], tt.want) {
+				t.Errorf("Request() got = % x, want % x", got, tt.want)
+			}
+
+			report := <-reports
+			wantReport := append([]byte{0x00}, buildFrame(tt.command, tt.payload)...)
+			if !reflect.DeepEqual(report[:len(wantReport)], wantReport) {
+				t.Errorf("Request() report = % x, want % x", report, wantReport)
+			}
+		})
+	}
+}
+
+func TestHidrawConnection_RequestTimeout(t *testing.T) {
+	host, device := net.Pipe()
+	defer host.Close()
+	defer device.Close()
+
+	go func() {
+		report := make([]byte, 1+HidrawPacketSize)
+		_, _ = device.Read(report) // never answer
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	c := &HidrawConnection{file: host}
+	if _, err := c.Request(ctx, 0x03, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Request() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestHidrawConnection_RequestClosed(t *testing.T) {
+	c := NewHidrawConnection(DeviceSelector{})
+	if _, err := c.Request(context.Background(), 0x03, nil); !errors.Is(err, ErrDisconnected) {
+		t.Errorf("Request() error = %v, want %v", err, ErrDisconnected)
+	}
+}
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WatchUsbDevice reports whenever the presence of a DNT RoomLogg PRO device matching the selector changes.
+// gousb does not expose the libusb hotplug callbacks, so the device list is polled in the given interval.
+// The first value reports the initial presence. The returned channel is closed once done is closed.
+func WatchUsbDevice(selector DeviceSelector, interval time.Duration, done <-chan struct{}) <-chan bool {
+	return watchPresence(func() (bool, error) {
+		devices, err := ListUsbDevices()
+		if err != nil {
+			return false, err
+		}
+		for _, device := range devices {
+			if selector.Matches(device) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}, interval, done)
+}
+
+func watchPresence(check func() (bool, error), interval time.Duration, done <-chan struct{}) <-chan bool {
+	events := make(chan bool, 1)
+
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		known := false
+		first := true
+		for {
+			present, err := check()
+			switch {
+			case err != nil:
+				logrus.Warnf("Failed to check for DNT RoomLogg PRO device: %v", err)
+			case first || present != known:
+				select {
+				case events <- present:
+				case <-done:
+					return
+				}
+				known = present
+				first = false
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return events
+}
+
+// Backoff calculates exponentially growing delays between retries.
+type Backoff struct {
+	Min time.Duration
+	Max time.Duration
+
+	current time.Duration
+}
+
+// Next returns the delay before the next retry.
+func (b *Backoff) Next() time.Duration {
+	if b.current == 0 {
+		b.current = b.Min
+	} else {
+		b.current *= 2
+	}
+	if b.current > b.Max {
+		b.current = b.Max
+	}
+	return b.current
+}
+
+// Reset starts over with the minimum delay.
+func (b *Backoff) Reset() {
+	b.current = 0
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchPresence(t *testing.T) {
+	states := []bool{true, true, false, false, true}
+	index := 0
+	mux := sync.Mutex{}
+	check := func() (bool, error) {
+		mux.Lock()
+		defer mux.Unlock()
+
+		state := states[len(states)-1]
+		if index < len(states) {
+			state = states[index]
+			index++
+		}
+		return state, nil
+	}
+
+	done := make(chan struct{})
+	events := watchPresence(check, time.Millisecond, done)
+
+	var got []bool
+	for i := 0; i < 3; i++ {
+		got = append(got, <-events)
+	}
+	close(done)
+	for range events {
+		// drain until the watcher stopped
+	}
+
+	want := []bool{true, false, true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("watchPresence() got = %v, want %v", got, want)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Min: time.Second, Max: 5 * time.Second}
+
+	var got []time.Duration
+	for i := 0; i < 5; i++ {
+		got = append(got, b.Next())
+	}
+	b.Reset()
+	got = append(got, b.Next())
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second, time.Second}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Next() got = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ScriptedPacketSize is the size of a scripted response packet, it matches the max packet size of the USB endpoints.
+const ScriptedPacketSize = 64
+
+// ScriptedRequest is a request that was sent to a ScriptedConnection.
+type ScriptedRequest struct {
+	Command    byte
+	Payload    []byte
+	NoResponse bool
+}
+
+// ScriptedConnection is an in-memory connection that answers requests with previously queued responses.
+// It can be used instead of a UsbConnection if no base station is attached.
+type ScriptedConnection struct {
+	mux       sync.Mutex
+	isOpen    bool
+	responses map[byte][][]byte // queued raw responses per command
+	requests  []ScriptedRequest
+}
+
+func NewScriptedConnection() *ScriptedConnection {
+	c := &ScriptedConnection{
+		responses: make(map[byte][][]byte),
+	}
+
+	return c
+}
+
+func (c *ScriptedConnection) Open() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.isOpen = true
+
+	return nil
+}
+
+func (c *ScriptedConnection) Close() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.isOpen = false
+}
+
+// AddResponse queues a response for the given command. The payload gets wrapped in the message start and end bytes.
+func (c *ScriptedConnection) AddResponse(command byte, payload []byte) {
+	var body = make([]byte, 0, 3+len(payload)) // 3 for start/end bytes
+	body = append(body, MessageStart...)
+	body = append(body, payload...)
+	body = append(body, MessageEnd...)
+
+	c.AddRawResponse(command, body)
+}
+
+// AddRawResponse queues a raw response for the given command. The raw bytes are returned as they are, only padding
+// is applied.
+func (c *ScriptedConnection) AddRawResponse(command byte, raw []byte) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.responses[command] = append(c.responses[command], raw)
+}
+
+// Requests returns all requests that have been sent so far.
+func (c *ScriptedConnection) Requests() []ScriptedRequest {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	requests := make([]ScriptedRequest, len(c.requests))
+	copy(requests, c.requests)
+
+	return requests
+}
+
+// PendingResponses returns the number of queued responses that have not been consumed yet.
+func (c *ScriptedConnection) PendingResponses() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	pending := 0
+	for _, responses := range c.responses {
+		pending += len(responses)
+	}
+
+	return pending
+}
+
+func (c *ScriptedConnection) Request(ctx context.Context, command byte, payload []byte, noResponse ...bool) ([]byte, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("scripted request for command 0x%02x aborted: %w", command, err)
+	}
+
+	if !c.isOpen {
+		return nil, fmt.Errorf("%w: scripted connection is not open", ErrDisconnected)
+	}
+
+	skipResponse := noResponse != nil && len(noResponse) > 0 && noResponse[0] == true
+	payloadCopy := make([]byte, len(payload))
+	copy(payloadCopy, payload)
+	c.requests = append(c.requests, ScriptedRequest{Command: command, Payload: payloadCopy, NoResponse: skipResponse})
+
+	if skipResponse {
+		return nil, nil
+	}
+
+	queue := c.responses[command]
+	if len(queue) == 0 {
+		return nil, fmt.Errorf("%w: no scripted response for command 0x%02x", ErrTimeout, command)
+	}
+	c.responses[command] = queue[1:]
+
+	// Apply padding to full packets, like the USB endpoint does
+	response := make([]byte, 0, ScriptedPacketSize)
+	response = append(response, queue[0]...)
+	for len(response) == 0 || len(response)%ScriptedPacketSize != 0 {
+		response = append(response, 0)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// HexBytes is a byte slice that is written as hex string to a trace.
+type HexBytes []byte
+
+func (b HexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(b))
+}
+
+func (b *HexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*b = raw
+
+	return nil
+}
+
+// TraceEntry is one request/response exchange with the station.
+type TraceEntry struct {
+	Time       time.Time     `json:"time"`
+	Duration   time.Duration `json:"duration"`
+	Command    byte          `json:"command"`
+	Name       string        `json:"name"`
+	Request    HexBytes      `json:"request"`            // outgoing frame without padding
+	Response   HexBytes      `json:"response,omitempty"` // raw response as read from the device
+	NoResponse bool          `json:"no_response,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	ErrorKind  string        `json:"error_kind,omitempty"` // timeout, disconnected or incomplete_frame
+}
+
+// traceErrorKinds are the errors that are restored on replay, in order of precedence.
+var traceErrorKinds = []struct {
+	name string
+	err  error
+}{
+	{"incomplete_frame", ErrIncompleteFrame},
+	{"timeout", ErrTimeout},
+	{"disconnected", ErrDisconnected},
+	{"deadline", context.DeadlineExceeded},
+	{"canceled", context.Canceled},
+}
+
+// TraceRecorder writes exchanges as JSON lines, one entry per line.
+type TraceRecorder struct {
+	mux   sync.Mutex
+	w     io.Writer
+	names func(command byte) string
+}
+
+// NewTraceRecorder creates a recorder that writes to w. The names function returns the name of a command.
+func NewTraceRecorder(w io.Writer, names func(command byte) string) *TraceRecorder {
+	return &TraceRecorder{w: w, names: names}
+}
+
+// Record writes one exchange. A nil recorder records nothing, so connections can call it unconditionally.
+func (t *TraceRecorder) Record(start time.Time, command byte, request, response []byte, noResponse bool, err error) {
+	if t == nil {
+		return
+	}
+
+	entry := TraceEntry{
+		Time:       start,
+		Duration:   time.Since(start),
+		Command:    command,
+		Request:    request,
+		Response:   response,
+		NoResponse: noResponse,
+	}
+	if t.names != nil {
+		entry.Name = t.names(command)
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		for _, kind := range traceErrorKinds {
+			if errors.Is(err, kind.err) {
+				entry.ErrorKind = kind.name
+				break
+			}
+		}
+	}
+
+	line, jsonErr := json.Marshal(entry)
+	if jsonErr != nil {
+		return
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	_, _ = t.w.Write(append(line, '\n'))
+}
+
+// ReadTrace reads all entries of a trace.
+func ReadTrace(r io.Reader) ([]TraceEntry, error) {
+	var entries []TraceEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*MaxResponseSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry TraceEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid trace entry in line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// ReplayConnection answers requests with the responses of a recorded trace. The requests must be sent in the same
+// order as they were recorded.
+type ReplayConnection struct {
+	mux     sync.Mutex
+	entries []TraceEntry
+	next    int
+	isOpen  bool
+}
+
+// NewReplayConnection creates a connection that replays the given trace entries.
+func NewReplayConnection(entries []TraceEntry) *ReplayConnection {
+	c := &ReplayConnection{entries: entries}
+
+	return c
+}
+
+func (c *ReplayConnection) Open() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.isOpen = true
+
+	return nil
+}
+
+func (c *ReplayConnection) Close() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.isOpen = false
+}
+
+// Remaining returns the number of trace entries that have not been replayed yet.
+func (c *ReplayConnection) Remaining() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return len(c.entries) - c.next
+}
+
+func (c *ReplayConnection) Request(ctx context.Context, command byte, payload []byte, noResponse ...bool) ([]byte, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("replayed request for command 0x%02x aborted: %w", command, err)
+	}
+	if !c.isOpen {
+		return nil, fmt.Errorf("%w: replay connection is not open", ErrDisconnected)
+	}
+	if c.next >= len(c.entries) {
+		return nil, fmt.Errorf("trace exhausted, no entry for command 0x%02x", command)
+	}
+
+	entry := c.entries[c.next]
+	request := buildFrame(command, payload)
+	if entry.Command != command || string(entry.Request) != string(request) {
+		return nil, fmt.Errorf("trace entry %d mismatch: recorded request % x, got % x", c.next, []byte(entry.Request), request)
+	}
+	c.next++
+
+	if entry.Error != "" {
+		for _, kind := range traceErrorKinds {
+			if kind.name == entry.ErrorKind {
+				return nil, fmt.Errorf("%w: replayed error: %s", kind.err, entry.Error)
+			}
+		}
+		return nil, fmt.Errorf("replayed error: %s", entry.Error)
+	}
+	if len(noResponse) > 0 && noResponse[0] {
+		return nil, nil
+	}
+
+	response := make([]byte, len(entry.Response))
+	copy(response, entry.Response)
+
+	return response, nil
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTraceRecordAndReplay(t *testing.T) {
+	buf := &bytes.Buffer{}
+	recorder := NewTraceRecorder(buf, func(command byte) string { return fmt.Sprintf("cmd%02x", command) })
+
+	start := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	recorder.Record(start, 0x03, buildFrame(0x03, nil), []byte{0x7b, 0x00, 0x25, 0x46, 0x40, 0x7d}, false, nil)
+	recorder.Record(start, 0x0b, buildFrame(0x0b, []byte{0x01}), nil, true, nil)
+	recorder.Record(start, 0x04, buildFrame(0x04, nil), nil, false, fmt.Errorf("%w: read failed", ErrTimeout))
+
+	entries, err := ReadTrace(buf)
+	if err != nil {
+		t.Fatalf("ReadTrace() error = %v", err)
+	}
+	if len(entries) != 3 || entries[0].Name != "cmd03" || !entries[0].Time.Equal(start) || entries[2].ErrorKind != "timeout" {
+		t.Fatalf("ReadTrace() got = %+v", entries)
+	}
+
+	c := NewReplayConnection(entries)
+	if err := c.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	got, err := c.Request(context.Background(), 0x03, nil)
+	if err != nil || !reflect.DeepEqual(got, []byte{0x7b, 0x00, 0x25, 0x46, 0x40, 0x7d}) {
+		t.Errorf("Request() got = % x, %v", got, err)
+	}
+	if got, err := c.Request(context.Background(), 0x0b, []byte{0x01}, true); err != nil || got != nil {
+		t.Errorf("Request() got = % x, %v, want no response", got, err)
+	}
+	if _, err := c.Request(context.Background(), 0x04, nil); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Request() error = %v, want %v", err, ErrTimeout)
+	}
+	if _, err := c.Request(context.Background(), 0x03, nil); err == nil {
+		t.Errorf("Request() expected error for exhausted trace")
+	}
+}
+
+func TestReplayConnection_Mismatch(t *testing.T) {
+	c := NewReplayConnection([]TraceEntry{{Command: 0x0b, Request: buildFrame(0x0b, []byte{0x01}), NoResponse: true}})
+	if err := c.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	if _, err := c.Request(context.Background(), 0x0b, []byte{0x00}, true); err == nil {
+		t.Errorf("Request() expected error for different payload")
+	}
+	if got := c.Remaining(); got != 1 {
+		t.Errorf("Remaining() got = %v, want %v", got, 1)
+	}
+}
+
+func TestReadTraceInvalid(t *testing.T) {
+	if _, err := ReadTrace(strings.NewReader("{\"command\":3,\"request\":\"zz\"}\n")); err == nil {
+		t.Errorf("ReadTrace() expected error for invalid hex")
+	}
+}
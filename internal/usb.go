@@ -1,9 +1,12 @@
+//go:build cgo
+
 package internal
 
 import (
-	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/gousb/usbid"
 
@@ -11,12 +14,9 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-var (
-	MessageStart = []byte{0x7b}
-	MessageEnd   = []byte{0x40, 0x7d}
-)
-
 type UsbConnection struct {
+	selector DeviceSelector
+
 	// Only one context should be needed for an application.  It should always be closed.
 	outEndpoint *gousb.OutEndpoint
 	inEndpoint  *gousb.InEndpoint
@@ -24,10 +24,18 @@ type UsbConnection struct {
 	dev         *gousb.Device
 	iface       *gousb.Interface
 	config      *gousb.Config
+
+	trace *TraceRecorder // records all exchanges if set
 }
 
+// NewUsbConnection creates a connection to the only attached DNT RoomLogg PRO device.
 func NewUsbConnection() *UsbConnection {
-	c := &UsbConnection{}
+	return NewUsbConnectionForDevice(DeviceSelector{})
+}
+
+// NewUsbConnectionForDevice creates a connection to the DNT RoomLogg PRO device that matches the given selector.
+func NewUsbConnectionForDevice(selector DeviceSelector) *UsbConnection {
+	c := &UsbConnection{selector: selector}
 
 	return c
 }
@@ -35,18 +43,24 @@ func NewUsbConnection() *UsbConnection {
 func (c *UsbConnection) Open() error {
 	c.ctx = gousb.NewContext()
 
-	devs, err := c.ctx.OpenDevices(findUsbDevice())
+	devs, err := c.ctx.OpenDevices(findUsbDevice(c.selector))
 	if err != nil {
+		for _, d := range devs {
+			d.Close()
+		}
 		logrus.Errorf("Failed to open DNT RoomLogg PRO device: %v", err)
 		return err
 	}
 	switch {
 	case len(devs) == 0:
-		logrus.Errorf("No DNT RoomLogg PRO device found.")
-		return errors.New("no DNT RoomLogg PRO device found")
+		logrus.Errorf("No DNT RoomLogg PRO device found (%s).", c.selector)
+		return fmt.Errorf("%w (%s)", ErrDeviceNotFound, c.selector)
 	case len(devs) > 1:
-		logrus.Errorf("Found multiple DNT RoomLogg PRO devices, only one device is supported.")
-		return errors.New("found multiple DNT RoomLogg PRO devices, only one device is supported")
+		for _, d := range devs {
+			d.Close()
+		}
+		logrus.Errorf("Found multiple DNT RoomLogg PRO devices, select one device by bus/address or port path.")
+		return fmt.Errorf("%w, select one device by bus/address or port path", ErrMultipleDevices)
 	}
 
 	c.dev = devs[0]
@@ -89,22 +103,38 @@ func (c *UsbConnection) Open() error {
 	return nil
 }
 
+// WatchPresence reports whenever the device of this connection is plugged in or removed.
+func (c *UsbConnection) WatchPresence(interval time.Duration, done <-chan struct{}) <-chan bool {
+	return WatchUsbDevice(c.selector, interval, done)
+}
+
 func (c *UsbConnection) Close() {
+	// Reset all handles, a request after Close must fail with ErrDisconnected instead of using freed handles
+	c.outEndpoint = nil
+	c.inEndpoint = nil
 	if c.iface != nil {
 		c.iface.Close()
+		c.iface = nil
 	}
 	if c.config != nil {
 		c.config.Close()
+		c.config = nil
 	}
 	if c.dev != nil {
 		c.dev.Close()
+		c.dev = nil
 	}
 	if c.ctx != nil {
 		c.ctx.Close()
+		c.ctx = nil
 	}
 }
 
-func (c *UsbConnection) rawRequest(body []byte, noResponse ...bool) ([]byte, error) {
+func (c *UsbConnection) rawRequest(ctx context.Context, body []byte, noResponse ...bool) ([]byte, error) {
+	if c.outEndpoint == nil || c.inEndpoint == nil {
+		return nil, fmt.Errorf("%w: connection is not open", ErrDisconnected)
+	}
+
 	lenBodyBytes := len(body)
 	lenPaddedBytes := c.outEndpoint.Desc.MaxPacketSize
 	// Apply padding
@@ -116,10 +146,14 @@ func (c *UsbConnection) rawRequest(body []byte, noResponse ...bool) ([]byte, err
 	}
 
 	logrus.Tracef("Writing raw message: %d bytes, %d bytes with padding", lenBodyBytes, lenPaddedBytes)
-	numWrittenBytes, err := c.outEndpoint.Write(body)
+	numWrittenBytes, err := c.outEndpoint.WriteContext(ctx, body)
+	if ctx.Err() != nil {
+		logrus.Errorf("Failed to write raw bytes: %v", ctx.Err())
+		return nil, fmt.Errorf("failed to write raw bytes: %w", ctx.Err())
+	}
 	if err != nil || numWrittenBytes != lenPaddedBytes {
 		logrus.Errorf("Failed to write raw bytes: %v. Written: %d bytes", err, numWrittenBytes)
-		return nil, fmt.Errorf("failed to write raw bytes: %v, written: %d bytes", err, numWrittenBytes)
+		return nil, fmt.Errorf("%w: failed to write raw bytes: %v, written: %d bytes", transferError(err), err, numWrittenBytes)
 	}
 	logrus.Tracef("Written data to device: %d bytes", numWrittenBytes)
 
@@ -127,28 +161,49 @@ func (c *UsbConnection) rawRequest(body []byte, noResponse ...bool) ([]byte, err
 		return nil, nil
 	}
 
-	// Buffer large enough for 1 USB packets (64 bytes) from in-endpoint.
-	logrus.Tracef("Reading raw message: %d bytes", c.inEndpoint.Desc.MaxPacketSize)
-	inData := make([]byte, c.inEndpoint.Desc.MaxPacketSize)
-	// numReadBytes might be smaller than the buffer size. numReadBytes might be greater than zero even if err is not nil.
-	numReadBytes, err := c.inEndpoint.Read(inData)
+	logrus.Tracef("Reading raw message: %d bytes per packet", c.inEndpoint.Desc.MaxPacketSize)
+	read := func(buf []byte) (int, error) {
+		return c.inEndpoint.ReadContext(ctx, buf)
+	}
+	inData, err := readFrame(read, c.inEndpoint.Desc.MaxPacketSize, MaxResponseSize)
+	if ctx.Err() != nil {
+		logrus.Errorf("Failed to read raw bytes: %v", ctx.Err())
+		return nil, fmt.Errorf("failed to read raw bytes: %w", ctx.Err())
+	}
 	if err != nil {
 		logrus.Errorf("Failed to read raw bytes: %v", err)
-		return nil, fmt.Errorf("failed to read raw bytes: %v, read %d bytes", err, numReadBytes)
+		if errors.Is(err, ErrIncompleteFrame) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", transferError(err), err)
 	}
-	logrus.Tracef("Read %d bytes from device", numReadBytes)
+	logrus.Tracef("Read %d bytes from device", len(inData))
 
 	return inData, nil
 }
 
-func (c *UsbConnection) Request(command byte, payload []byte, noResponse ...bool) ([]byte, error) {
-	var body = make([]byte, 0, 4+len(payload)) // 4 for command and start/end bytes
-	body = append(body, MessageStart...)
-	body = append(body, command)
-	body = append(body, payload...)
-	body = append(body, MessageEnd...)
+// transferError maps a failed USB transfer to ErrTimeout or ErrDisconnected.
+func transferError(err error) error {
+	if errors.Is(err, gousb.ErrorTimeout) || errors.Is(err, gousb.TransferTimedOut) {
+		return ErrTimeout
+	}
+
+	return ErrDisconnected
+}
+
+// Request sends the command to the device and reads the response. The context bounds both, the USB write and read.
+func (c *UsbConnection) Request(ctx context.Context, command byte, payload []byte, noResponse ...bool) ([]byte, error) {
+	start := time.Now()
+	frame := buildFrame(command, payload)
+	response, err := c.rawRequest(ctx, frame, noResponse...)
+	c.trace.Record(start, command, frame, response, len(noResponse) > 0 && noResponse[0], err)
+
+	return response, err
+}
 
-	return c.rawRequest(body, noResponse...)
+// SetTrace enables recording of all exchanges, nil disables the recording.
+func (c *UsbConnection) SetTrace(trace *TraceRecorder) {
+	c.trace = trace
 }
 
 func getUsbEndpoints(desc *gousb.DeviceDesc) (in int, out int) {
@@ -169,14 +224,18 @@ func getUsbEndpoints(desc *gousb.DeviceDesc) (in int, out int) {
 	return
 }
 
-func findUsbDevice() func(desc *gousb.DeviceDesc) bool {
+func isRoomLoggDevice(desc *gousb.DeviceDesc) bool {
+	return desc.Product == gousb.ID(0x5750) && desc.Vendor == gousb.ID(0x0483)
+}
+
+func findUsbDevice(selector DeviceSelector) func(desc *gousb.DeviceDesc) bool {
 	return func(desc *gousb.DeviceDesc) bool {
 
 		// The usbid package can be used to print out human readable information.
 		logrus.Debugf("Checking USB device: %03d.%03d %s:%s %s", desc.Bus, desc.Address, desc.Vendor, desc.Product, usbid.Describe(desc))
 
-		if desc.Product == gousb.ID(0x5750) && desc.Vendor == gousb.ID(0x0483) {
-			logrus.Infof("Found DNT RoomLogg PRO on USB bus %03d.%03d", desc.Bus, desc.Address)
+		if isRoomLoggDevice(desc) && selector.Matches(newDeviceInfo(desc)) {
+			logrus.Infof("Found DNT RoomLogg PRO on USB bus %03d.%03d (port %s)", desc.Bus, desc.Address, newDeviceInfo(desc).PortPath())
 
 			return true
 		}
@@ -185,17 +244,28 @@ func findUsbDevice() func(desc *gousb.DeviceDesc) bool {
 	}
 }
 
-func GetMessagePayload(raw []byte) ([]byte, error) {
-	if raw == nil || len(raw) < 3 {
-		return nil, errors.New("invalid raw message size")
-	}
-
-	startIndex := 1 // First byte can be dismissed, its 0x7b (MessageStart)
-	endIndex := bytes.Index(raw, MessageEnd)
+// ListUsbDevices returns all attached DNT RoomLogg PRO devices without opening them.
+func ListUsbDevices() ([]DeviceInfo, error) {
+	ctx := gousb.NewContext()
+	defer ctx.Close()
 
-	if endIndex == -1 {
-		return nil, errors.New("unable to find message end")
+	var devices []DeviceInfo
+	devs, err := ctx.OpenDevices(func(desc *gousb.DeviceDesc) bool {
+		if isRoomLoggDevice(desc) {
+			devices = append(devices, newDeviceInfo(desc))
+		}
+		return false // only list devices, do not open them
+	})
+	for _, d := range devs {
+		d.Close()
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
 
-	return raw[startIndex:endIndex], nil
+func newDeviceInfo(desc *gousb.DeviceDesc) DeviceInfo {
+	return DeviceInfo{Bus: desc.Bus, Address: desc.Address, Path: desc.Path}
 }
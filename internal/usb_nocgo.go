@@ -0,0 +1,51 @@
+//go:build !cgo
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errNoLibusb is returned by all libusb functions if the binary was built without cgo.
+var errNoLibusb = errors.New("libusb backend is not available without cgo, use the hidraw backend")
+
+// UsbConnection is not available without cgo, all requests fail.
+type UsbConnection struct {
+	selector DeviceSelector
+}
+
+// NewUsbConnection creates a connection to the only attached DNT RoomLogg PRO device.
+func NewUsbConnection() *UsbConnection {
+	return NewUsbConnectionForDevice(DeviceSelector{})
+}
+
+// NewUsbConnectionForDevice creates a connection to the DNT RoomLogg PRO device that matches the given selector.
+func NewUsbConnectionForDevice(selector DeviceSelector) *UsbConnection {
+	return &UsbConnection{selector: selector}
+}
+
+func (c *UsbConnection) Open() error {
+	return errNoLibusb
+}
+
+// WatchPresence reports whenever the device of this connection is plugged in or removed.
+func (c *UsbConnection) WatchPresence(interval time.Duration, done <-chan struct{}) <-chan bool {
+	return WatchUsbDevice(c.selector, interval, done)
+}
+
+func (c *UsbConnection) Close() {}
+
+// SetTrace enables recording of all exchanges, nil disables the recording.
+func (c *UsbConnection) SetTrace(_ *TraceRecorder) {}
+
+func (c *UsbConnection) Request(_ context.Context, _ byte, _ []byte, _ ...bool) ([]byte, error) {
+	return nil, fmt.Errorf("%w: %v", ErrDisconnected, errNoLibusb)
+}
+
+// ListUsbDevices returns all attached DNT RoomLogg PRO devices without opening them.
+func ListUsbDevices() ([]DeviceInfo, error) {
+	return nil, errNoLibusb
+}
@@ -0,0 +1,210 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AlarmRange raises an alarm if the value drops below Low or rises above High.
+type AlarmRange struct {
+	Low  float64 `yaml:"low"`
+	High float64 `yaml:"high"`
+}
+
+// AlarmConfig are the alarm thresholds of a channel. A missing range leaves the alarm of the station unchanged.
+type AlarmConfig struct {
+	Temperature *AlarmRange `yaml:"temperature"` // in the configured temperature unit, the station unit if none is set
+	Humidity    *AlarmRange `yaml:"humidity"`    // %
+}
+
+// AlarmRegistry maps channels to their alarm thresholds, the keys are the same as in the ChannelRegistry.
+type AlarmRegistry map[string]*AlarmConfig
+
+// Decode parses the registry from an environment variable. Channels are separated by commas, each channel consists of
+// the key and the ranges, e.g. 1:temperature=18..25;humidity=40..60,garage/4:temperature=-10..35
+func (r *AlarmRegistry) Decode(value string) error {
+	registry := AlarmRegistry{}
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		key, ranges, ok := strings.Cut(entry, ":")
+		if !ok {
+			return fmt.Errorf("%w: alarm %q has no thresholds", ErrInvalidValue, entry)
+		}
+		key = strings.TrimSpace(key)
+
+		alarm := &AlarmConfig{}
+		for _, field := range strings.Split(ranges, ";") {
+			name, value, _ := strings.Cut(field, "=")
+			alarmRange, err := parseAlarmRange(value)
+			if err != nil {
+				return fmt.Errorf("alarm %s: %w", key, err)
+			}
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case "temperature":
+				alarm.Temperature = alarmRange
+			case "humidity":
+				alarm.Humidity = alarmRange
+			default:
+				return fmt.Errorf("%w: alarm %s: unknown value %q", ErrInvalidValue, key, name)
+			}
+		}
+		registry[key] = alarm
+	}
+
+	if err := registry.Validate(); err != nil {
+		return err
+	}
+	*r = registry
+
+	return nil
+}
+
+// parseAlarmRange parses a range of the form low..high.
+func parseAlarmRange(value string) (*AlarmRange, error) {
+	low, high, ok := strings.Cut(strings.TrimSpace(value), "..")
+	if !ok {
+		return nil, fmt.Errorf("%w: range %q is not of the form low..high", ErrInvalidValue, value)
+	}
+
+	lowValue, err := strconv.ParseFloat(low, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: range %q: low %q is not a number", ErrInvalidValue, value, low)
+	}
+	highValue, err := strconv.ParseFloat(high, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: range %q: high %q is not a number", ErrInvalidValue, value, high)
+	}
+
+	return &AlarmRange{Low: lowValue, High: highValue}, nil
+}
+
+// Validate checks the channel keys and that each range is within the limits of the station.
+func (r AlarmRegistry) Validate() error {
+	for key, alarm := range r {
+		if err := validateChannelKey(key); err != nil {
+			return err
+		}
+		if alarm == nil {
+			return fmt.Errorf("%w: alarm %s has no thresholds", ErrInvalidValue, key)
+		}
+		if err := alarm.Temperature.validate(-3276.8, 3276.7); err != nil {
+			return fmt.Errorf("alarm %s: temperature %w", key, err)
+		}
+		if err := alarm.Humidity.validate(0, 100); err != nil {
+			return fmt.Errorf("alarm %s: humidity %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+func (a *AlarmRange) validate(min, max float64) error {
+	if a == nil {
+		return nil
+	}
+	if a.Low > a.High {
+		return fmt.Errorf("%w: low %v is above high %v", ErrInvalidValue, a.Low, a.High)
+	}
+	if a.Low < min || a.High > max {
+		return fmt.Errorf("%w: %v..%v is not within %v..%v", ErrInvalidValue, a.Low, a.High, min, max)
+	}
+
+	return nil
+}
+
+// Lookup returns the alarm thresholds of the channel of the given station, nil if the channel is not configured.
+// A station specific entry takes precedence over an entry for all stations.
+func (r AlarmRegistry) Lookup(station string, channel int) *AlarmConfig {
+	if station != "" {
+		if alarm, ok := r[fmt.Sprintf("%s/%d", station, channel)]; ok {
+			return alarm
+		}
+	}
+
+	return r[strconv.Itoa(channel)]
+}
+
+// ApplyAlarms writes the configured alarm thresholds to the station and enables the low and high alarms of the
+// configured channels. Alarms of other channels are left unchanged. The station is read and written in one exchange.
+func (r *RoomLogg) ApplyAlarms() error {
+	return r.ApplyAlarmsContext(context.Background())
+}
+
+// ApplyAlarmsContext is like ApplyAlarms but aborts once the context is done.
+func (r *RoomLogg) ApplyAlarmsContext(ctx context.Context) error {
+	if r.cfg == nil || len(r.cfg.Alarms) == 0 {
+		return nil
+	}
+
+	canonicalUnit, convert, err := r.cfg.CanonicalUnit()
+	if err != nil {
+		return err
+	}
+
+	// Read and write in one exchange, so that concurrent changes are neither lost nor overwritten with stale values
+	if err := r.tryLock(ctx); err != nil {
+		return err
+	}
+	defer r.unlock()
+
+	settings, err := r.fetchSettings(ctx)
+	if err != nil {
+		return err
+	}
+	alarmSettings, err := r.fetchAlarmSettings(ctx)
+	if err != nil {
+		return err
+	}
+	temperatureAlarms, err := r.fetchTemperatureAlarms(ctx)
+	if err != nil {
+		return err
+	}
+	humidityAlarms, err := r.fetchHumidityAlarms(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range temperatureAlarms {
+		alarm := r.cfg.Alarms.Lookup(r.id, i+1)
+		if alarm == nil {
+			continue
+		}
+		if alarm.Temperature != nil {
+			low, high := alarm.Temperature.Low, alarm.Temperature.High
+			if convert {
+				low = roundTenth(ConvertTemperature(low, canonicalUnit, settings.Units))
+				high = roundTenth(ConvertTemperature(high, canonicalUnit, settings.Units))
+			}
+			temperatureAlarms[i].Low, temperatureAlarms[i].High = low, high
+			alarmSettings.TemperatureLowAlarm[uint8(i)] = true
+			alarmSettings.TemperatureHighAlarm[uint8(i)] = true
+			alarmSettings.EnableTemperatureAlarm = AlarmOn
+		}
+		if alarm.Humidity != nil && i < len(humidityAlarms) {
+			humidityAlarms[i].Low, humidityAlarms[i].High = alarm.Humidity.Low, alarm.Humidity.High
+			alarmSettings.HumidityLowAlarm[uint8(i)] = true
+			alarmSettings.HumidityHighAlarm[uint8(i)] = true
+			alarmSettings.EnableHumidityAlarm = AlarmOn
+		}
+	}
+
+	if err := validateChannels("temperature alarms", temperatureAlarms); err != nil {
+		return err
+	}
+	if err := validateChannels("humidity alarms", humidityAlarms); err != nil {
+		return err
+	}
+	if err := r.setTemperatureAlarms(ctx, temperatureAlarms); err != nil {
+		return err
+	}
+	if err := r.setHumidityAlarms(ctx, humidityAlarms); err != nil {
+		return err
+	}
+
+	return r.setAlarmSettings(ctx, alarmSettings)
+}
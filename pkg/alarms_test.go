@@ -0,0 +1,157 @@
+package pkg
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAlarmRegistry_Decode(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    AlarmRegistry
+		wantErr error
+	}{
+		{
+			name:  "Full",
+			value: "1:temperature=18..25;humidity=40..60,garage/4:temperature=-10.5..35",
+			want: AlarmRegistry{
+				"1":        {Temperature: &AlarmRange{Low: 18, High: 25}, Humidity: &AlarmRange{Low: 40, High: 60}},
+				"garage/4": {Temperature: &AlarmRange{Low: -10.5, High: 35}},
+			},
+		},
+		{name: "Empty", value: "", want: AlarmRegistry{}},
+		{name: "MissingThresholds", value: "1", wantErr: ErrInvalidValue},
+		{name: "InvalidChannel", value: "0:humidity=40..60", wantErr: ErrInvalidValue},
+		{name: "InvalidRange", value: "1:humidity=40-60", wantErr: ErrInvalidValue},
+		{name: "InvalidNumber", value: "1:humidity=low..60", wantErr: ErrInvalidValue},
+		{name: "LowAboveHigh", value: "1:temperature=25..18", wantErr: ErrInvalidValue},
+		{name: "HumidityLimit", value: "1:humidity=40..120", wantErr: ErrInvalidValue},
+		{name: "UnknownValue", value: "1:pressure=900..1100", wantErr: ErrInvalidValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got AlarmRegistry
+			err := got.Decode(tt.value)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Decode() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoomLogg_ApplyAlarms(t *testing.T) {
+	cfg := &RoomLoggConfig{
+		PollingRate:     60,
+		TemperatureUnit: "fahrenheit",
+		Alarms: AlarmRegistry{
+			"1":        {Temperature: &AlarmRange{Low: 64.4, High: 77}, Humidity: &AlarmRange{Low: 40, High: 60}},
+			"3":        {Humidity: &AlarmRange{Low: 30, High: 70}},
+			"garage/3": {Humidity: &AlarmRange{Low: 20, High: 80}},
+		},
+	}
+	r := NewRoomLoggWithTransport(cfg, NewEmulator())
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+
+	before, err := r.FetchTemperatureAlarms()
+	if err != nil {
+		t.Fatalf("FetchTemperatureAlarms() error = %v", err)
+	}
+	if err := r.ApplyAlarms(); err != nil {
+		t.Fatalf("ApplyAlarms() error = %v", err)
+	}
+
+	temperatureAlarms, err := r.FetchTemperatureAlarms()
+	if err != nil {
+		t.Fatalf("FetchTemperatureAlarms() error = %v", err)
+	}
+	wantTemperature := &TemperatureAlarmData{Channel: 1, Low: 18, High: 25} // the emulated station uses celsius
+	if !reflect.DeepEqual(temperatureAlarms[0], wantTemperature) {
+		t.Errorf("ApplyAlarms() temperature = %v, want %v", temperatureAlarms[0], wantTemperature)
+	}
+	if !reflect.DeepEqual(temperatureAlarms[1:], before[1:]) {
+		t.Errorf("ApplyAlarms() changed temperature alarms of unconfigured channels")
+	}
+
+	humidityAlarms, err := r.FetchHumidityAlarms()
+	if err != nil {
+		t.Fatalf("FetchHumidityAlarms() error = %v", err)
+	}
+	wantHumidity := []*HumidityAlarmData{{Channel: 1, Low: 40, High: 60}, {Channel: 3, Low: 30, High: 70}}
+	if got := []*HumidityAlarmData{humidityAlarms[0], humidityAlarms[2]}; !reflect.DeepEqual(got, wantHumidity) {
+		t.Errorf("ApplyAlarms() humidity = %v, want %v", got, wantHumidity)
+	}
+
+	settings, err := r.FetchAlarmSettings()
+	if err != nil {
+		t.Fatalf("FetchAlarmSettings() error = %v", err)
+	}
+	if settings.EnableTemperatureAlarm != AlarmOn || settings.EnableHumidityAlarm != AlarmOn {
+		t.Errorf("ApplyAlarms() enabled = %v, %v, want %v", settings.EnableTemperatureAlarm,
+			settings.EnableHumidityAlarm, AlarmOn)
+	}
+	if !settings.TemperatureHighAlarm[0] || settings.TemperatureHighAlarm[2] || !settings.HumidityLowAlarm[2] {
+		t.Errorf("ApplyAlarms() channel alarms = %v, %v", settings.TemperatureHighAlarm, settings.HumidityLowAlarm)
+	}
+}
+
+func TestRoomLogg_ApplyAlarmsConcurrentSetSettings(t *testing.T) {
+	var r *RoomLogg
+	var mux sync.Mutex
+	var commands []byte
+	concurrent := make(chan error, 1)
+	var once sync.Once
+
+	transport := &hookTransport{Transport: NewEmulator(), before: func(command byte) {
+		mux.Lock()
+		commands = append(commands, command)
+		mux.Unlock()
+
+		if command != CommandGetSettings {
+			return
+		}
+		once.Do(func() { // change the settings while the alarms are applied
+			go func() {
+				settings, _ := NewSettingsData(make([]byte, settingsSize))
+				settings.Units = UnitFahrenheit
+				concurrent <- r.SetSettings(settings)
+			}()
+			time.Sleep(50 * time.Millisecond)
+		})
+	}}
+	cfg := &RoomLoggConfig{PollingRate: 60, Alarms: AlarmRegistry{"1": {Temperature: &AlarmRange{Low: 18, High: 25}}}}
+	r = NewRoomLoggWithTransport(cfg, transport)
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+
+	if err := r.ApplyAlarms(); err != nil {
+		t.Fatalf("ApplyAlarms() error = %v", err)
+	}
+	if err := <-concurrent; err != nil {
+		t.Fatalf("SetSettings() error = %v", err)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	want := []byte{
+		CommandGetSettings, CommandGetAlarmSettings, CommandGetTemperatureAlarm, CommandGetHumidityAlarm,
+		CommandStartStore, CommandSetTemperatureAlarm, CommandEndStore,
+		CommandStartStore, CommandSetHumidityAlarm, CommandEndStore,
+		CommandStartStore, CommandSetAlarmSettings, CommandEndStore,
+		CommandStartStore, CommandSetSettings, CommandEndStore, // the concurrent SetSettings runs afterwards
+	}
+	if !reflect.DeepEqual(commands, want) {
+		t.Errorf("ApplyAlarms() commands = % x, want % x", commands, want)
+	}
+}
@@ -1,167 +1,610 @@
 package pkg
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"io"
+	"sync"
+	"time"
 
 	"github.com/h44z/dntroomloggpro-go/internal"
 	"github.com/sirupsen/logrus"
 )
 
+// RoomLogg is a connection to one DNT RoomLogg PRO base station. It is safe for concurrent use, every method runs its
+// request/response exchanges exclusively. Multi step exchanges like start-store, set and end-store are never
+// interleaved with the exchanges of other callers.
 type RoomLogg struct {
 	// Only one context should be needed for an application.  It should always be closed.
-	cfg *RoomLoggConfig
-	usb *internal.UsbConnection
+	cfg       *RoomLoggConfig
+	id        string
+	transport Transport
+	trace     io.Closer     // trace file, if exchanges are recorded
+	lock      chan struct{} // serializes exchanges with the station, a channel so that waiting callers can be cancelled
+
+	// temperature unit of the station, taken from the last settings exchange, guarded by lock
+	unit      Unit
+	unitKnown bool
+
+	// connection state
+	state       ConnectionState
+	handlers    []ConnectionHandler
+	events      []pendingEvent // state changes that wait for the handlers, in order
+	dispatching bool           // set while a goroutine runs the handlers
+	monitorDone chan struct{}
+	monitorExit chan struct{} // closed once the monitor goroutine has returned
+	stateMux    sync.Mutex
 }
 
-func NewRoomLogg(cfg *RoomLoggConfig) *RoomLogg {
-	r := &RoomLogg{cfg: cfg}
-	r.usb = internal.NewUsbConnection()
+// Backends to access the USB device.
+const (
+	BackendLibusb = "libusb" // libusb via gousb, requires cgo
+	BackendHidraw = "hidraw" // Linux hidraw device nodes, pure Go
+)
+
+// NewRoomLogg creates a new RoomLogg instance that talks to the base station using the configured backend.
+// If emulation is enabled in the config, an emulated base station is used instead. An invalid device selector,
+// backend or trace file is returned as error.
+func NewRoomLogg(cfg *RoomLoggConfig) (*RoomLogg, error) {
+	return NewRoomLoggStation(cfg, "", cfg.Device)
+}
+
+// NewRoomLoggStation creates a new RoomLogg instance for the station with the given ID. The device selector
+// (bus.address or port path) picks one of multiple attached base stations.
+func NewRoomLoggStation(cfg *RoomLoggConfig, id, device string) (*RoomLogg, error) {
+	if cfg.Emulate {
+		logrus.Infof("Using emulated DNT RoomLogg PRO base station %q", id)
+		r := NewRoomLoggWithTransport(cfg, NewEmulator())
+		r.id = id
+		return r, nil
+	}
+
+	selector, err := internal.ParseDeviceSelector(device)
+	if err != nil {
+		return nil, err
+	}
+
+	var transport Transport
+	switch cfg.Backend {
+	case BackendLibusb, "":
+		transport = internal.NewUsbConnectionForDevice(selector)
+	case BackendHidraw:
+		transport = internal.NewHidrawConnection(selector)
+	default:
+		return nil, fmt.Errorf("%w: unsupported backend %q", ErrInvalidValue, cfg.Backend)
+	}
+
+	r := NewRoomLoggWithTransport(cfg, transport)
+	r.id = id
+
+	if t, ok := transport.(tracer); ok && cfg.TraceFile != "" {
+		file, recorder, err := openTrace(cfg.TraceFile, id)
+		if err != nil {
+			return nil, err
+		}
+		logrus.Infof("Recording exchanges with DNT RoomLogg PRO %q to %s", id, file.Name())
+		t.SetTrace(recorder)
+		r.trace = file
+	}
+
+	return r, nil
+}
+
+// NewRoomLoggWithTransport creates a new RoomLogg instance that uses the given transport.
+func NewRoomLoggWithTransport(cfg *RoomLoggConfig, transport Transport) *RoomLogg {
+	r := &RoomLogg{cfg: cfg, transport: transport, lock: make(chan struct{}, 1)}
 
 	return r
 }
 
+// ListDevices returns a description of all DNT RoomLogg PRO devices that are attached and accessible with the given
+// backend. The descriptions contain the bus address and port path that can be used as device selector.
+func ListDevices(backend string) ([]string, error) {
+	switch backend {
+	case BackendLibusb, "":
+		devices, err := internal.ListUsbDevices()
+		if err != nil {
+			return nil, err
+		}
+
+		descriptions := make([]string, len(devices))
+		for i, device := range devices {
+			descriptions[i] = device.String()
+		}
+		return descriptions, nil
+	case BackendHidraw:
+		devices, err := internal.ListHidrawDevices()
+		if err != nil {
+			return nil, err
+		}
+
+		descriptions := make([]string, len(devices))
+		for i, device := range devices {
+			descriptions[i] = fmt.Sprintf("%s at %s", device.Info, device.Node)
+		}
+		return descriptions, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported backend %q", ErrInvalidValue, backend)
+	}
+}
+
+// ID returns the station identifier, it is empty if only one station is used.
+func (r *RoomLogg) ID() string {
+	return r.id
+}
+
 func (r *RoomLogg) Open() error {
-	return r.usb.Open()
+	r.lock <- struct{}{}
+	err := r.transport.Open()
+	r.unlock()
+
+	return r.opened(err)
+}
+
+// opened updates the connection state after the transport has been opened.
+func (r *RoomLogg) opened(err error) error {
+	if err != nil {
+		r.setState(StateDisconnected, err)
+		return err
+	}
+	r.setState(StateConnected, nil)
+
+	return nil
 }
 
+// Close stops the device monitor and closes the connection. It does not emit a connection event.
 func (r *RoomLogg) Close() {
-	r.usb.Close()
+	r.StopMonitor()
+
+	r.lock <- struct{}{}
+	defer r.unlock()
+
+	r.transport.Close()
+	if r.trace != nil {
+		r.trace.Close()
+		r.trace = nil
+	}
 }
 
 func (r *RoomLogg) Reconnect() error {
-	r.Close()
-	err := r.Open()
+	// Close and open in one step, so that no other exchange runs in between
+	r.lock <- struct{}{}
+	r.transport.Close()
+	err := r.transport.Open()
+	r.unlock()
+
+	return r.opened(err)
+}
+
+// tryLock waits until the transport is available or the context is done.
+func (r *RoomLogg) tryLock(ctx context.Context) error {
+	select {
+	case r.lock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *RoomLogg) unlock() {
+	<-r.lock
+}
+
+// withTimeout applies the configured request timeout if the context has no deadline yet.
+func (r *RoomLogg) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || r.cfg == nil || r.cfg.RequestTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, time.Duration(r.cfg.RequestTimeout)*time.Second)
+}
+
+// request sends a single request to the station. The caller must hold the transport lock.
+func (r *RoomLogg) request(ctx context.Context, command byte, payload []byte, noResponse ...bool) ([]byte, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	response, err := r.transport.Request(ctx, command, payload, noResponse...)
 	if err != nil {
-		return err
+		return nil, newRequestError(command, err)
 	}
-	return nil
+
+	return response, nil
+}
+
+// fetch sends a request and returns the payload of the response frame. Responses of commands with a fixed payload
+// size must match that size, otherwise they belong to another command. The caller must hold the transport lock.
+func (r *RoomLogg) fetch(ctx context.Context, command byte, payload []byte) ([]byte, error) {
+	dataBytes, err := r.request(ctx, command, payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(dataBytes) == 0 || dataBytes[0] != internal.MessageStart[0] {
+		return nil, newRequestError(command, fmt.Errorf("%w: missing message start", ErrBadFrame))
+	}
+
+	var responsePayload []byte
+	if size, ok := responsePayloadSizes[command]; ok {
+		responsePayload, err = internal.GetMessagePayloadOfSize(dataBytes, size)
+	} else {
+		responsePayload, err = internal.GetMessagePayload(dataBytes)
+	}
+	if err != nil {
+		return nil, newRequestError(command, err)
+	}
+
+	return responsePayload, nil
 }
 
 func (r *RoomLogg) FetchCurrentData() ([]*ChannelData, error) { // Returns already calibrated data
-	dataBytes, err := r.usb.Request(CommandGetCurrentData, nil)
-	if err != nil || dataBytes[0] != internal.MessageStart[0] {
-		logrus.Errorf("Failed to fetch current data: %v", err)
+	return r.FetchCurrentDataContext(context.Background())
+}
+
+// FetchCurrentDataContext is like FetchCurrentData but aborts once the context is done.
+//
+// The current data does not contain the temperature unit. Unless the settings were fetched or set before, the first
+// call therefore runs an additional GetSettings exchange to read the unit of the station.
+func (r *RoomLogg) FetchCurrentDataContext(ctx context.Context) ([]*ChannelData, error) {
+	if err := r.tryLock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.unlock()
+
+	canonicalUnit, convert, channels, err := r.unitSettings(ctx)
+	if err != nil {
 		return nil, err
 	}
 
-	payload, err := internal.GetMessagePayload(dataBytes)
+	payload, err := r.fetch(ctx, CommandGetCurrentData, nil)
 	if err != nil {
-		logrus.Errorf("Failed to validate message payload: %v", err)
+		logrus.Errorf("Failed to fetch current data: %v", err)
 		return nil, err
 	}
 
-	return NewChannelsData(payload), nil
+	data, err := NewChannelsData(payload)
+	if err != nil {
+		logrus.Errorf("Failed to decode current data: %v", err)
+		return nil, newRequestError(CommandGetCurrentData, err)
+	}
+	for _, channel := range data {
+		channel.Unit = r.unit
+		channel.Info = channels.Lookup(r.id, channel.Number)
+	}
+	if convert {
+		data = ConvertChannels(data, canonicalUnit)
+	}
+
+	return data, nil
+}
+
+// unitSettings returns the canonical temperature unit, whether readings must be converted to it and the channel
+// registry of the config. The station unit is read from the settings if it is not known yet. The caller must hold
+// the lock.
+func (r *RoomLogg) unitSettings(ctx context.Context) (Unit, bool, ChannelRegistry, error) {
+	var canonicalUnit Unit
+	var convert bool
+	var channels ChannelRegistry
+	if r.cfg != nil {
+		var err error
+		if canonicalUnit, convert, err = r.cfg.CanonicalUnit(); err != nil {
+			return 0, false, nil, err
+		}
+		channels = r.cfg.Channels
+	}
+	if !r.unitKnown {
+		if _, err := r.fetchSettings(ctx); err != nil {
+			return 0, false, nil, err
+		}
+	}
+
+	return canonicalUnit, convert, channels, nil
 }
 
 func (r *RoomLogg) FetchCalibrationData() ([]*CalibrationData, error) {
-	dataBytes, err := r.usb.Request(CommandGetCalibration, nil)
-	if err != nil || dataBytes[0] != internal.MessageStart[0] {
-		logrus.Errorf("Failed to fetch calibration data: %v", err)
+	return r.FetchCalibrationDataContext(context.Background())
+}
+
+// FetchCalibrationDataContext is like FetchCalibrationData but aborts once the context is done.
+func (r *RoomLogg) FetchCalibrationDataContext(ctx context.Context) ([]*CalibrationData, error) {
+	if err := r.tryLock(ctx); err != nil {
 		return nil, err
 	}
+	defer r.unlock()
 
-	payload, err := internal.GetMessagePayload(dataBytes)
+	payload, err := r.fetch(ctx, CommandGetCalibration, nil)
 	if err != nil {
-		logrus.Errorf("Failed to validate message payload: %v", err)
+		logrus.Errorf("Failed to fetch calibration data: %v", err)
 		return nil, err
 	}
 
-	return NewCalibrationsData(payload), nil
+	data, err := NewCalibrationsData(payload)
+	if err != nil {
+		logrus.Errorf("Failed to decode calibration data: %v", err)
+		return nil, newRequestError(CommandGetCalibration, err)
+	}
+
+	return data, nil
 }
 
 func (r *RoomLogg) FetchIntervalMinutes() (IntervalData, error) {
-	dataBytes, err := r.usb.Request(CommandGetInterval, nil)
-	if err != nil || dataBytes[0] != internal.MessageStart[0] {
-		logrus.Errorf("Failed to fetch interval data: %v", err)
+	return r.FetchIntervalMinutesContext(context.Background())
+}
+
+// FetchIntervalMinutesContext is like FetchIntervalMinutes but aborts once the context is done.
+func (r *RoomLogg) FetchIntervalMinutesContext(ctx context.Context) (IntervalData, error) {
+	if err := r.tryLock(ctx); err != nil {
 		return 0, err
 	}
+	defer r.unlock()
 
-	payload, err := internal.GetMessagePayload(dataBytes)
+	payload, err := r.fetch(ctx, CommandGetInterval, nil)
 	if err != nil {
-		logrus.Errorf("Failed to validate message payload: %v", err)
+		logrus.Errorf("Failed to fetch interval data: %v", err)
 		return 0, err
 	}
 
-	return NewIntervalData(payload), nil
+	data, err := NewIntervalData(payload)
+	if err != nil {
+		logrus.Errorf("Failed to decode interval data: %v", err)
+		return 0, newRequestError(CommandGetInterval, err)
+	}
+
+	return data, nil
 }
 
 func (r *RoomLogg) FetchSettings() (*SettingsData, error) {
-	dataBytes, err := r.usb.Request(CommandGetSettings, nil)
-	if err != nil || dataBytes[0] != internal.MessageStart[0] {
-		logrus.Errorf("Failed to fetch settings data: %v", err)
+	return r.FetchSettingsContext(context.Background())
+}
+
+// FetchSettingsContext is like FetchSettings but aborts once the context is done.
+func (r *RoomLogg) FetchSettingsContext(ctx context.Context) (*SettingsData, error) {
+	if err := r.tryLock(ctx); err != nil {
 		return nil, err
 	}
+	defer r.unlock()
+
+	return r.fetchSettings(ctx)
+}
 
-	payload, err := internal.GetMessagePayload(dataBytes)
+// fetchSettings fetches the settings and remembers the temperature unit of the station. The caller must hold the
+// transport lock.
+func (r *RoomLogg) fetchSettings(ctx context.Context) (*SettingsData, error) {
+	payload, err := r.fetch(ctx, CommandGetSettings, nil)
 	if err != nil {
-		logrus.Errorf("Failed to validate message payload: %v", err)
+		logrus.Errorf("Failed to fetch settings data: %v", err)
 		return nil, err
 	}
 
-	return NewSettingsData(payload), nil
+	data, err := NewSettingsData(payload)
+	if err != nil {
+		logrus.Errorf("Failed to decode settings data: %v", err)
+		return nil, newRequestError(CommandGetSettings, err)
+	}
+	r.unit, r.unitKnown = data.Units, true
+
+	return data, nil
 }
 
 func (r *RoomLogg) FetchAlarmSettings() (*AlarmSettingsData, error) {
-	dataBytes, err := r.usb.Request(CommandGetAlarmSettings, nil)
-	if err != nil || dataBytes[0] != internal.MessageStart[0] {
-		logrus.Errorf("Failed to fetch alarm settings data: %v", err)
+	return r.FetchAlarmSettingsContext(context.Background())
+}
+
+// FetchAlarmSettingsContext is like FetchAlarmSettings but aborts once the context is done.
+func (r *RoomLogg) FetchAlarmSettingsContext(ctx context.Context) (*AlarmSettingsData, error) {
+	if err := r.tryLock(ctx); err != nil {
 		return nil, err
 	}
+	defer r.unlock()
+
+	return r.fetchAlarmSettings(ctx)
+}
 
-	payload, err := internal.GetMessagePayload(dataBytes)
+// fetchAlarmSettings fetches the alarm settings. The caller must hold the transport lock.
+func (r *RoomLogg) fetchAlarmSettings(ctx context.Context) (*AlarmSettingsData, error) {
+	payload, err := r.fetch(ctx, CommandGetAlarmSettings, nil)
 	if err != nil {
-		logrus.Errorf("Failed to validate message payload: %v", err)
+		logrus.Errorf("Failed to fetch alarm settings data: %v", err)
 		return nil, err
 	}
 
-	return NewAlarmSettingsData(payload), nil
+	data, err := NewAlarmSettingsData(payload)
+	if err != nil {
+		logrus.Errorf("Failed to decode alarm settings data: %v", err)
+		return nil, newRequestError(CommandGetAlarmSettings, err)
+	}
+
+	return data, nil
 }
 
 func (r *RoomLogg) FetchTemperatureAlarms() ([]*TemperatureAlarmData, error) {
-	dataBytes, err := r.usb.Request(CommandGetTemperatureAlarm, nil)
-	if err != nil || dataBytes[0] != internal.MessageStart[0] {
-		logrus.Errorf("Failed to fetch temperature alarm data: %v", err)
+	return r.FetchTemperatureAlarmsContext(context.Background())
+}
+
+// FetchTemperatureAlarmsContext is like FetchTemperatureAlarms but aborts once the context is done.
+func (r *RoomLogg) FetchTemperatureAlarmsContext(ctx context.Context) ([]*TemperatureAlarmData, error) {
+	if err := r.tryLock(ctx); err != nil {
 		return nil, err
 	}
+	defer r.unlock()
+
+	return r.fetchTemperatureAlarms(ctx)
+}
 
-	payload, err := internal.GetMessagePayload(dataBytes)
+// fetchTemperatureAlarms fetches the temperature alarm thresholds. The caller must hold the transport lock.
+func (r *RoomLogg) fetchTemperatureAlarms(ctx context.Context) ([]*TemperatureAlarmData, error) {
+	payload, err := r.fetch(ctx, CommandGetTemperatureAlarm, nil)
 	if err != nil {
-		logrus.Errorf("Failed to validate message payload: %v", err)
+		logrus.Errorf("Failed to fetch temperature alarm data: %v", err)
 		return nil, err
 	}
 
-	return NewTemperatureAlarmsData(payload), nil
+	data, err := NewTemperatureAlarmsData(payload)
+	if err != nil {
+		logrus.Errorf("Failed to decode temperature alarm data: %v", err)
+		return nil, newRequestError(CommandGetTemperatureAlarm, err)
+	}
+
+	return data, nil
 }
 
 func (r *RoomLogg) FetchHumidityAlarms() ([]*HumidityAlarmData, error) {
-	dataBytes, err := r.usb.Request(CommandGetHumidityAlarm, nil)
-	if err != nil || dataBytes[0] != internal.MessageStart[0] {
+	return r.FetchHumidityAlarmsContext(context.Background())
+}
+
+// FetchHumidityAlarmsContext is like FetchHumidityAlarms but aborts once the context is done.
+func (r *RoomLogg) FetchHumidityAlarmsContext(ctx context.Context) ([]*HumidityAlarmData, error) {
+	if err := r.tryLock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.unlock()
+
+	return r.fetchHumidityAlarms(ctx)
+}
+
+// fetchHumidityAlarms fetches the humidity alarm thresholds. The caller must hold the transport lock.
+func (r *RoomLogg) fetchHumidityAlarms(ctx context.Context) ([]*HumidityAlarmData, error) {
+	payload, err := r.fetch(ctx, CommandGetHumidityAlarm, nil)
+	if err != nil {
 		logrus.Errorf("Failed to fetch humidity alarm data: %v", err)
 		return nil, err
 	}
 
-	payload, err := internal.GetMessagePayload(dataBytes)
+	data, err := NewHumidityAlarmsData(payload)
+	if err != nil {
+		logrus.Errorf("Failed to decode humidity alarm data: %v", err)
+		return nil, newRequestError(CommandGetHumidityAlarm, err)
+	}
+
+	return data, nil
+}
+
+// FetchSDCardHistory downloads all records that the base station logged to its SD card.
+// The file is transferred page by page, each page is requested separately. Like FetchCurrentData, the channels carry
+// the station unit, or are converted to RoomLoggConfig.TemperatureUnit.
+//
+// Experimental: the page and record layout (see FilePageData and HistoryRecord) is not documented by the vendor and
+// has not been verified against a real base station yet. Record a protocol trace before relying on the records. The
+// download is only sent if RoomLoggConfig.SDCard is enabled, otherwise ErrNotEnabled is returned.
+func (r *RoomLogg) FetchSDCardHistory() ([]*HistoryRecord, error) {
+	return r.FetchSDCardHistoryContext(context.Background())
+}
+
+// FetchSDCardHistoryContext is like FetchSDCardHistory but aborts once the context is done.
+func (r *RoomLogg) FetchSDCardHistoryContext(ctx context.Context) ([]*HistoryRecord, error) {
+	if err := r.checkSDCard(); err != nil {
+		return nil, err
+	}
+	loc, err := r.location()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.tryLock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.unlock()
+
+	canonicalUnit, convert, channels, err := r.unitSettings(ctx)
 	if err != nil {
-		logrus.Errorf("Failed to validate message payload: %v", err)
 		return nil, err
 	}
 
-	return NewHumidityAlarmsData(payload), nil
+	rawRecords, err := r.fetchFile(ctx, CommandGetSDCardFile, "sd card")
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := NewHistoryRecords(rawRecords)
+	if err != nil {
+		logrus.Errorf("Failed to decode sd card records: %v", err)
+		return nil, newRequestError(CommandGetSDCardFile, err)
+	}
+	for _, record := range records {
+		record.Time = inLocation(record.Time, loc) // records are logged in station time
+		for _, channel := range record.Channels {
+			channel.Unit = r.unit
+			channel.Info = channels.Lookup(r.id, channel.Number)
+		}
+		if convert {
+			record.Channels = ConvertChannels(record.Channels, canonicalUnit)
+		}
+	}
+
+	return records, nil
+}
+
+// FetchExportFile downloads the export file of the base station memory. Like the SD card file, the export file is
+// transferred page by page.
+//
+// Experimental: like FetchSDCardHistory, the record layout (see ExportRecord) has not been verified against a real
+// base station yet. The download is only sent if RoomLoggConfig.ExportFile is enabled, otherwise ErrNotEnabled is
+// returned.
+func (r *RoomLogg) FetchExportFile() (ExportData, error) {
+	return r.FetchExportFileContext(context.Background())
+}
+
+// FetchExportFileContext is like FetchExportFile but aborts once the context is done.
+func (r *RoomLogg) FetchExportFileContext(ctx context.Context) (ExportData, error) {
+	if err := r.checkExportFile(); err != nil {
+		return nil, err
+	}
+	loc, err := r.location()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.tryLock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.unlock()
+
+	canonicalUnit, convert, _, err := r.unitSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawRecords, err := r.fetchFile(ctx, CommandGetExportFile, "export")
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := NewExportData(rawRecords)
+	if err != nil {
+		logrus.Errorf("Failed to decode export records: %v", err)
+		return nil, newRequestError(CommandGetExportFile, err)
+	}
+	for i, record := range records {
+		record.Time = inLocation(record.Time, loc) // records are logged in station time
+		record.Unit = r.unit
+		if convert {
+			records[i] = record.ConvertTo(canonicalUnit)
+		}
+	}
+
+	return records, nil
 }
 
 func (r *RoomLogg) SetIntervalMinutes(minutes IntervalData) error {
+	return r.SetIntervalMinutesContext(context.Background(), minutes)
+}
+
+// SetIntervalMinutesContext is like SetIntervalMinutes but aborts once the context is done.
+func (r *RoomLogg) SetIntervalMinutesContext(ctx context.Context, minutes IntervalData) error {
 	if minutes < 0 || minutes > 240 {
-		return errors.New("value out of range")
+		return fmt.Errorf("%w: interval %d out of range", ErrInvalidValue, minutes)
 	}
+
+	if err := r.tryLock(ctx); err != nil {
+		return err
+	}
+	defer r.unlock()
+
 	// Interval sync has no start-store command
 
-	if _, err := r.usb.Request(CommandSetInterval, minutes.RawBytes(), true); err != nil {
+	if _, err := r.request(ctx, CommandSetInterval, minutes.RawBytes(), true); err != nil {
 		logrus.Errorf("Failed to set interval data: %v", err)
 		return err
 	}
 
-	if err := r.endStore(); err != nil {
+	if err := r.endStore(ctx); err != nil {
 		return err
 	}
 
@@ -169,32 +612,158 @@ func (r *RoomLogg) SetIntervalMinutes(minutes IntervalData) error {
 }
 
 func (r *RoomLogg) SetLanguage(lang LanguageData) error {
+	return r.SetLanguageContext(context.Background(), lang)
+}
+
+// SetLanguageContext is like SetLanguage but aborts once the context is done.
+func (r *RoomLogg) SetLanguageContext(ctx context.Context, lang LanguageData) error {
 	if lang < 0 || lang > 1 {
-		return errors.New("value out of range")
+		return fmt.Errorf("%w: language %d out of range", ErrInvalidValue, lang)
+	}
+
+	if err := r.tryLock(ctx); err != nil {
+		return err
 	}
+	defer r.unlock()
 
-	if err := r.startStore(); err != nil {
+	if err := r.startStore(ctx); err != nil {
 		return err
 	}
 
-	if _, err := r.usb.Request(CommandSetLanguage, lang.RawBytes(), true); err != nil {
+	if _, err := r.request(ctx, CommandSetLanguage, lang.RawBytes(), true); err != nil {
 		logrus.Errorf("Failed to set language data: %v", err)
 		return err
 	}
 
-	if err := r.endStore(); err != nil {
+	if err := r.endStore(ctx); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// FetchTime reads the clock of the base station. The settings are read in the same exchange, the DST flag is part of
+// the returned timezone. The get-time command is experimental, it is only sent if RoomLoggConfig.GetTime is enabled,
+// otherwise ErrNotEnabled is returned.
+func (r *RoomLogg) FetchTime() (TimeData, error) {
+	return r.FetchTimeContext(context.Background())
+}
+
+// FetchTimeContext is like FetchTime but aborts once the context is done.
+func (r *RoomLogg) FetchTimeContext(ctx context.Context) (TimeData, error) {
+	if err := r.checkGetTime(); err != nil {
+		return TimeData{}, err
+	}
+	if err := r.tryLock(ctx); err != nil {
+		return TimeData{}, err
+	}
+	defer r.unlock()
+
+	settings, err := r.fetchSettings(ctx)
+	if err != nil {
+		return TimeData{}, err
+	}
+
+	payload, err := r.fetch(ctx, CommandGetTime, nil)
+	if err != nil {
+		logrus.Errorf("Failed to fetch time data: %v", err)
+		return TimeData{}, err
+	}
+
+	data, err := newTimeData(payload, settings.DST == DSTOn)
+	if err != nil {
+		logrus.Errorf("Failed to decode time data: %v", err)
+		return TimeData{}, newRequestError(CommandGetTime, err)
+	}
+
+	return data, nil
+}
+
+// FetchClockDrift reads the clock of the base station and compares it with the host clock. The host time is taken
+// halfway through the request, so the transfer time does not count as drift. Like FetchTime, it requires
+// RoomLoggConfig.GetTime.
+func (r *RoomLogg) FetchClockDrift() (*ClockDrift, error) {
+	return r.FetchClockDriftContext(context.Background())
+}
+
+// FetchClockDriftContext is like FetchClockDrift but aborts once the context is done.
+func (r *RoomLogg) FetchClockDriftContext(ctx context.Context) (*ClockDrift, error) {
+	if err := r.checkGetTime(); err != nil {
+		return nil, err
+	}
+	if err := r.tryLock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.unlock()
+
+	settings, err := r.fetchSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	payload, err := r.fetch(ctx, CommandGetTime, nil)
+	if err != nil {
+		logrus.Errorf("Failed to fetch time data: %v", err)
+		return nil, err
+	}
+	host := start.Add(time.Since(start) / 2)
+
+	data, err := newTimeData(payload, settings.DST == DSTOn)
+	if err != nil {
+		logrus.Errorf("Failed to decode time data: %v", err)
+		return nil, newRequestError(CommandGetTime, err)
+	}
+
+	return NewClockDrift(data, host), nil
+}
+
+// checkGetTime returns ErrNotEnabled unless the experimental get-time command is enabled in the config.
+func (r *RoomLogg) checkGetTime() error {
+	return checkExperimental(CommandGetTime, r.cfg != nil && r.cfg.GetTime, "get-time", "EXPERIMENTAL_GET_TIME")
+}
+
+// checkSDCard returns ErrNotEnabled unless the experimental SD card file download is enabled in the config.
+func (r *RoomLogg) checkSDCard() error {
+	return checkExperimental(CommandGetSDCardFile, r.cfg != nil && r.cfg.SDCard, "sd card file", "EXPERIMENTAL_SD_CARD")
+}
+
+// checkExportFile returns ErrNotEnabled unless the experimental export file download is enabled in the config.
+func (r *RoomLogg) checkExportFile() error {
+	return checkExperimental(CommandGetExportFile, r.cfg != nil && r.cfg.ExportFile, "export file",
+		"EXPERIMENTAL_EXPORT_FILE")
+}
+
+// checkExperimental returns ErrNotEnabled for the command unless it is enabled with the env variable.
+func checkExperimental(command byte, enabled bool, name, env string) error {
+	if !enabled {
+		return newRequestError(command,
+			fmt.Errorf("%w: %s is not verified on real stations, set %s to use it", ErrNotEnabled, name, env))
+	}
+	return nil
+}
+
 func (r *RoomLogg) SetTime(time TimeData) error {
-	if err := r.startStore(); err != nil {
+	return r.SetTimeContext(context.Background(), time)
+}
+
+// SetTimeContext is like SetTime but aborts once the context is done.
+func (r *RoomLogg) SetTimeContext(ctx context.Context, time TimeData) error {
+	if err := r.tryLock(ctx); err != nil {
 		return err
 	}
+	defer r.unlock()
 
-	if _, err := r.usb.Request(CommandSetTime, time.RawBytes(), true); err != nil {
+	return r.setTime(ctx, time)
+}
+
+// setTime writes the station clock. The caller must hold the transport lock.
+func (r *RoomLogg) setTime(ctx context.Context, time TimeData) error {
+	if err := r.startStore(ctx); err != nil {
+		return err
+	}
+
+	if _, err := r.request(ctx, CommandSetTime, time.RawBytes(), true); err != nil {
 		logrus.Errorf("Failed to set time data: %v", err)
 		return err
 	}
@@ -205,7 +774,21 @@ func (r *RoomLogg) SetTime(time TimeData) error {
 }
 
 func (r *RoomLogg) SetCalibrationData(calibration []*CalibrationData) error {
-	if err := r.startStore(); err != nil {
+	return r.SetCalibrationDataContext(context.Background(), calibration)
+}
+
+// SetCalibrationDataContext is like SetCalibrationData but aborts once the context is done.
+func (r *RoomLogg) SetCalibrationDataContext(ctx context.Context, calibration []*CalibrationData) error {
+	if err := validateChannels("calibration", calibration); err != nil {
+		return err
+	}
+
+	if err := r.tryLock(ctx); err != nil {
+		return err
+	}
+	defer r.unlock()
+
+	if err := r.startStore(ctx); err != nil {
 		return err
 	}
 
@@ -213,12 +796,12 @@ func (r *RoomLogg) SetCalibrationData(calibration []*CalibrationData) error {
 	for i := 0; i < 8; i++ {
 		rawBytes = append(rawBytes, calibration[i].RawBytes()...)
 	}
-	if _, err := r.usb.Request(CommandSetCalibration, rawBytes, true); err != nil {
+	if _, err := r.request(ctx, CommandSetCalibration, rawBytes, true); err != nil {
 		logrus.Errorf("Failed to set calibration data: %v", err)
 		return err
 	}
 
-	if err := r.endStore(); err != nil {
+	if err := r.endStore(ctx); err != nil {
 		return err
 	}
 
@@ -226,33 +809,73 @@ func (r *RoomLogg) SetCalibrationData(calibration []*CalibrationData) error {
 }
 
 func (r *RoomLogg) SetSettings(settings *SettingsData) error {
-	if err := r.startStore(); err != nil {
+	return r.SetSettingsContext(context.Background(), settings)
+}
+
+// SetSettingsContext is like SetSettings but aborts once the context is done.
+func (r *RoomLogg) SetSettingsContext(ctx context.Context, settings *SettingsData) error {
+	if settings == nil {
+		return fmt.Errorf("%w: missing settings", ErrInvalidValue)
+	}
+
+	if err := r.tryLock(ctx); err != nil {
 		return err
 	}
+	defer r.unlock()
+
+	return r.setSettings(ctx, settings)
+}
 
-	if _, err := r.usb.Request(CommandSetSettings, settings.RawBytes(), true); err != nil {
+// setSettings writes the settings and remembers the temperature unit of the station. The caller must hold the
+// transport lock.
+func (r *RoomLogg) setSettings(ctx context.Context, settings *SettingsData) error {
+	if err := r.startStore(ctx); err != nil {
+		return err
+	}
+
+	if _, err := r.request(ctx, CommandSetSettings, settings.RawBytes(), true); err != nil {
 		logrus.Errorf("Failed to set settings data: %v", err)
 		return err
 	}
 
-	if err := r.endStore(); err != nil {
+	if err := r.endStore(ctx); err != nil {
 		return err
 	}
+	r.unit, r.unitKnown = settings.Units, true
 
 	return nil
 }
 
 func (r *RoomLogg) SetAlarmSettings(settings *AlarmSettingsData) error {
-	if err := r.startStore(); err != nil {
+	return r.SetAlarmSettingsContext(context.Background(), settings)
+}
+
+// SetAlarmSettingsContext is like SetAlarmSettings but aborts once the context is done.
+func (r *RoomLogg) SetAlarmSettingsContext(ctx context.Context, settings *AlarmSettingsData) error {
+	if settings == nil {
+		return fmt.Errorf("%w: missing alarm settings", ErrInvalidValue)
+	}
+
+	if err := r.tryLock(ctx); err != nil {
 		return err
 	}
+	defer r.unlock()
+
+	return r.setAlarmSettings(ctx, settings)
+}
 
-	if _, err := r.usb.Request(CommandSetAlarmSettings, settings.RawBytes(), true); err != nil {
+// setAlarmSettings writes the alarm settings. The caller must hold the transport lock.
+func (r *RoomLogg) setAlarmSettings(ctx context.Context, settings *AlarmSettingsData) error {
+	if err := r.startStore(ctx); err != nil {
+		return err
+	}
+
+	if _, err := r.request(ctx, CommandSetAlarmSettings, settings.RawBytes(), true); err != nil {
 		logrus.Errorf("Failed to set alarm settings data: %v", err)
 		return err
 	}
 
-	if err := r.endStore(); err != nil {
+	if err := r.endStore(ctx); err != nil {
 		return err
 	}
 
@@ -260,7 +883,26 @@ func (r *RoomLogg) SetAlarmSettings(settings *AlarmSettingsData) error {
 }
 
 func (r *RoomLogg) SetTemperatureAlarms(alarms []*TemperatureAlarmData) error {
-	if err := r.startStore(); err != nil {
+	return r.SetTemperatureAlarmsContext(context.Background(), alarms)
+}
+
+// SetTemperatureAlarmsContext is like SetTemperatureAlarms but aborts once the context is done.
+func (r *RoomLogg) SetTemperatureAlarmsContext(ctx context.Context, alarms []*TemperatureAlarmData) error {
+	if err := validateChannels("temperature alarms", alarms); err != nil {
+		return err
+	}
+
+	if err := r.tryLock(ctx); err != nil {
+		return err
+	}
+	defer r.unlock()
+
+	return r.setTemperatureAlarms(ctx, alarms)
+}
+
+// setTemperatureAlarms writes the temperature alarm thresholds. The caller must hold the transport lock.
+func (r *RoomLogg) setTemperatureAlarms(ctx context.Context, alarms []*TemperatureAlarmData) error {
+	if err := r.startStore(ctx); err != nil {
 		return err
 	}
 
@@ -268,12 +910,12 @@ func (r *RoomLogg) SetTemperatureAlarms(alarms []*TemperatureAlarmData) error {
 	for i := 0; i < 8; i++ {
 		rawBytes = append(rawBytes, alarms[i].RawBytes()...)
 	}
-	if _, err := r.usb.Request(CommandSetTemperatureAlarm, rawBytes, true); err != nil {
+	if _, err := r.request(ctx, CommandSetTemperatureAlarm, rawBytes, true); err != nil {
 		logrus.Errorf("Failed to set temperature alarm data: %v", err)
 		return err
 	}
 
-	if err := r.endStore(); err != nil {
+	if err := r.endStore(ctx); err != nil {
 		return err
 	}
 
@@ -281,7 +923,26 @@ func (r *RoomLogg) SetTemperatureAlarms(alarms []*TemperatureAlarmData) error {
 }
 
 func (r *RoomLogg) SetHumidityAlarms(alarms []*HumidityAlarmData) error {
-	if err := r.startStore(); err != nil {
+	return r.SetHumidityAlarmsContext(context.Background(), alarms)
+}
+
+// SetHumidityAlarmsContext is like SetHumidityAlarms but aborts once the context is done.
+func (r *RoomLogg) SetHumidityAlarmsContext(ctx context.Context, alarms []*HumidityAlarmData) error {
+	if err := validateChannels("humidity alarms", alarms); err != nil {
+		return err
+	}
+
+	if err := r.tryLock(ctx); err != nil {
+		return err
+	}
+	defer r.unlock()
+
+	return r.setHumidityAlarms(ctx, alarms)
+}
+
+// setHumidityAlarms writes the humidity alarm thresholds. The caller must hold the transport lock.
+func (r *RoomLogg) setHumidityAlarms(ctx context.Context, alarms []*HumidityAlarmData) error {
+	if err := r.startStore(ctx); err != nil {
 		return err
 	}
 
@@ -289,35 +950,77 @@ func (r *RoomLogg) SetHumidityAlarms(alarms []*HumidityAlarmData) error {
 	for i := 0; i < 8; i++ {
 		rawBytes = append(rawBytes, alarms[i].RawBytes()...)
 	}
-	if _, err := r.usb.Request(CommandSetHumidityAlarm, rawBytes, true); err != nil {
+	if _, err := r.request(ctx, CommandSetHumidityAlarm, rawBytes, true); err != nil {
 		logrus.Errorf("Failed to set humidity alarm data: %v", err)
 		return err
 	}
 
-	if err := r.endStore(); err != nil {
+	if err := r.endStore(ctx); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (r *RoomLogg) startStore() error {
-	dataBytes, err := r.usb.Request(CommandStartStore, nil)
+// validateChannels checks that there is a value for each of the 8 channels.
+func validateChannels[T any](name string, values []*T) error {
+	if len(values) != 8 {
+		return fmt.Errorf("%w: %s for %d channels, want 8", ErrInvalidValue, name, len(values))
+	}
+	for i, value := range values {
+		if value == nil {
+			return fmt.Errorf("%w: missing %s for channel %d", ErrInvalidValue, name, i+1)
+		}
+	}
+
+	return nil
+}
+
+// fetchFile requests all pages of a file and returns the concatenated records. The number of pages is reported by
+// the station, a file with more than maxFilePages pages is rejected.
+func (r *RoomLogg) fetchFile(ctx context.Context, command byte, name string) ([]byte, error) {
+	var records []byte
+	for page, pages := 0, 1; page < pages; page++ {
+		payload, err := r.fetch(ctx, command, FilePageRequestData(page).RawBytes())
+		if err != nil {
+			logrus.Errorf("Failed to fetch %s file page %d: %v", name, page, err)
+			return nil, err
+		}
+		pageData, err := NewFilePageData(payload)
+		if err != nil {
+			logrus.Errorf("Failed to fetch %s file page %d: %v", name, page, err)
+			return nil, newRequestError(command, err)
+		}
+		if pageData.Pages > maxFilePages {
+			logrus.Errorf("Failed to fetch %s file: station reports %d pages, at most %d are supported", name,
+				pageData.Pages, maxFilePages)
+			return nil, newRequestError(command, fmt.Errorf("%w: %s file has %d pages, at most %d are supported",
+				ErrBadFrame, name, pageData.Pages, maxFilePages))
+		}
+		pages = pageData.Pages
+		records = append(records, pageData.Records...)
+		logrus.Tracef("Fetched %s file page %d/%d", name, page+1, pages)
+	}
+
+	return records, nil
+}
+
+func (r *RoomLogg) startStore(ctx context.Context) error {
+	payload, err := r.fetch(ctx, CommandStartStore, nil)
 	if err != nil {
 		logrus.Errorf("Failed to start store command: %v", err)
 		return err
 	}
-	payload, err := internal.GetMessagePayload(dataBytes)
-	if err != nil || len(payload) != 1 || payload[0] != 0x00 {
-		logrus.Errorf("Failed to start store command, bad response: %v, %v", err, payload)
-		return fmt.Errorf("failed to start store command, bad response: %v, %v", err, payload)
+	if len(payload) != 1 || payload[0] != 0x00 {
+		logrus.Errorf("Failed to start store command, bad response: %v", payload)
+		return newRequestError(CommandStartStore, fmt.Errorf("%w: bad response % x", ErrStoreRejected, payload))
 	}
 
 	return nil
 }
 
-func (r *RoomLogg) endStore() error {
-	if _, err := r.usb.Request(CommandEndStore, nil, true); err != nil {
+func (r *RoomLogg) endStore(ctx context.Context) error {
+	if _, err := r.request(ctx, CommandEndStore, nil, true); err != nil {
 		logrus.Errorf("Failed to end store command: %v", err)
 		return err
 	}
@@ -0,0 +1,584 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/h44z/dntroomloggpro-go/internal"
+)
+
+func newScriptedRoomLogg(t *testing.T) (*RoomLogg, *internal.ScriptedConnection) {
+	t.Helper()
+
+	conn := internal.NewScriptedConnection()
+	r := NewRoomLoggWithTransport(&RoomLoggConfig{PollingRate: 60}, conn)
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	return r, conn
+}
+
+// addSettingsResponse scripts the settings response, the temperature unit is read before the first current data.
+func addSettingsResponse(conn *internal.ScriptedConnection, unit Unit) {
+	settings := make([]byte, settingsSize)
+	settings[6] = byte(unit)
+	conn.AddResponse(CommandGetSettings, settings)
+}
+
+func TestRoomLogg_FetchCurrentData(t *testing.T) {
+	r, conn := newScriptedRoomLogg(t)
+	defer r.Close()
+
+	addSettingsResponse(conn, UnitCelsius)
+	conn.AddResponse(CommandGetCurrentData, []byte{0x00, 0x25, 0x46, 0x7f, 0xff, 0xff, 0xFF, 0xFB, 0x09,
+		0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff})
+
+	got, err := r.FetchCurrentData()
+	if err != nil {
+		t.Fatalf("FetchCurrentData() error = %v", err)
+	}
+	want := []*ChannelData{
+		{Number: 1, Temperature: 3.7, Humidity: 70},
+		{Number: 3, Temperature: -0.5, Humidity: 9},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FetchCurrentData() got = %v, want %v", got, want)
+	}
+}
+
+func TestRoomLogg_FetchTime(t *testing.T) {
+	r, conn := newScriptedRoomLogg(t)
+	defer r.Close()
+	r.cfg.GetTime = true
+
+	addSettingsResponse(conn, UnitCelsius)
+	conn.AddResponse(CommandGetTime, []byte{0x07, 0xe4, 0x0c, 0x1c, 0x0f, 0x2b, 0x17, 0x01})
+
+	got, err := r.FetchTime()
+	if err != nil {
+		t.Fatalf("FetchTime() error = %v", err)
+	}
+	want := time.Date(2020, 12, 28, 15, 43, 23, 0, time.FixedZone("UTC+1", 60*60))
+	if !got.Time().Equal(want) || got.Time().String() != want.String() {
+		t.Errorf("FetchTime() got = %v, want %v", got.Time(), want)
+	}
+}
+
+func TestRoomLogg_ExperimentalNotEnabled(t *testing.T) {
+	r, conn := newScriptedRoomLogg(t)
+	defer r.Close()
+
+	if _, err := r.FetchTime(); !errors.Is(err, ErrNotEnabled) {
+		t.Errorf("FetchTime() error = %v, want %v", err, ErrNotEnabled)
+	}
+	if _, err := r.FetchClockDrift(); !errors.Is(err, ErrNotEnabled) {
+		t.Errorf("FetchClockDrift() error = %v, want %v", err, ErrNotEnabled)
+	}
+	if _, err := r.FetchSDCardHistory(); !errors.Is(err, ErrNotEnabled) {
+		t.Errorf("FetchSDCardHistory() error = %v, want %v", err, ErrNotEnabled)
+	}
+	if _, err := r.FetchExportFile(); !errors.Is(err, ErrNotEnabled) {
+		t.Errorf("FetchExportFile() error = %v, want %v", err, ErrNotEnabled)
+	}
+	if requests := conn.Requests(); len(requests) != 0 {
+		t.Errorf("sent requests = %v, want none", requests)
+	}
+}
+
+func TestRoomLogg_FetchIntervalMinutes(t *testing.T) {
+	r, conn := newScriptedRoomLogg(t)
+	defer r.Close()
+
+	conn.AddResponse(CommandGetInterval, []byte{0x0f})
+
+	got, err := r.FetchIntervalMinutes()
+	if err != nil {
+		t.Fatalf("FetchIntervalMinutes() error = %v", err)
+	}
+	if got != 15 {
+		t.Errorf("FetchIntervalMinutes() got = %v, want %v", got, 15)
+	}
+}
+
+func TestRoomLogg_FetchMissingResponse(t *testing.T) {
+	r, _ := newScriptedRoomLogg(t)
+	defer r.Close()
+
+	if _, err := r.FetchSettings(); err == nil {
+		t.Errorf("FetchSettings() expected error for missing response")
+	}
+}
+
+func TestRoomLogg_SetLanguage(t *testing.T) {
+	r, conn := newScriptedRoomLogg(t)
+	defer r.Close()
+
+	conn.AddResponse(CommandStartStore, []byte{0x00})
+
+	if err := r.SetLanguage(LanguageData(LanguageEN)); err != nil {
+		t.Fatalf("SetLanguage() error = %v", err)
+	}
+
+	want := []internal.ScriptedRequest{
+		{Command: CommandStartStore, Payload: []byte{}, NoResponse: false},
+		{Command: CommandSetLanguage, Payload: []byte{0x01}, NoResponse: true},
+		{Command: CommandEndStore, Payload: []byte{}, NoResponse: true},
+	}
+	if got := conn.Requests(); !reflect.DeepEqual(got, want) {
+		t.Errorf("SetLanguage() requests = %v, want %v", got, want)
+	}
+}
+
+func TestRoomLogg_SetLanguageStoreRejected(t *testing.T) {
+	r, conn := newScriptedRoomLogg(t)
+	defer r.Close()
+
+	conn.AddResponse(CommandStartStore, []byte{0x01})
+
+	if err := r.SetLanguage(LanguageData(LanguageDE)); err == nil {
+		t.Errorf("SetLanguage() expected error for rejected store command")
+	}
+	if got := len(conn.Requests()); got != 1 {
+		t.Errorf("SetLanguage() sent %d requests, want %d", got, 1)
+	}
+}
+
+func TestRoomLogg_FetchSDCardHistory(t *testing.T) {
+	r, conn := newScriptedRoomLogg(t)
+	defer r.Close()
+	r.cfg.SDCard = true
+
+	record1 := &HistoryRecord{
+		Time:     time.Date(2022, 8, 1, 10, 0, 0, 0, time.Local),
+		Channels: []*ChannelData{{Number: 1, Temperature: 21.3, Humidity: 48}},
+	}
+	record2 := &HistoryRecord{
+		Time:     time.Date(2022, 8, 1, 10, 5, 0, 0, time.Local),
+		Channels: []*ChannelData{{Number: 1, Temperature: 21.4, Humidity: 47}, {Number: 2, Temperature: -3, Humidity: 90}},
+	}
+	addSettingsResponse(conn, UnitCelsius)
+	conn.AddResponse(CommandGetSDCardFile, (&FilePageData{Pages: 2, Records: record1.RawBytes()}).RawBytes())
+	conn.AddResponse(CommandGetSDCardFile, (&FilePageData{Pages: 2, Records: record2.RawBytes()}).RawBytes())
+
+	got, err := r.FetchSDCardHistory()
+	if err != nil {
+		t.Fatalf("FetchSDCardHistory() error = %v", err)
+	}
+	want := []*HistoryRecord{record1, record2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FetchSDCardHistory() got = %v, want %v", got, want)
+	}
+
+	requests := conn.Requests()
+	if len(requests) != 3 || !reflect.DeepEqual(requests[2].Payload, []byte{0x00, 0x01}) {
+		t.Errorf("FetchSDCardHistory() requests = %v", requests)
+	}
+}
+
+func TestRoomLogg_FetchSDCardHistoryTooManyPages(t *testing.T) {
+	r, conn := newScriptedRoomLogg(t)
+	defer r.Close()
+	r.cfg.SDCard = true
+
+	addSettingsResponse(conn, UnitCelsius)
+	conn.AddResponse(CommandGetSDCardFile, (&FilePageData{Pages: maxFilePages + 1}).RawBytes())
+
+	if _, err := r.FetchSDCardHistory(); !errors.Is(err, ErrBadFrame) {
+		t.Errorf("FetchSDCardHistory() error = %v, want %v", err, ErrBadFrame)
+	}
+	if requests := conn.Requests(); len(requests) != 2 {
+		t.Errorf("FetchSDCardHistory() sent %d requests, want 2", len(requests))
+	}
+}
+
+// blockingTransport never answers a request, like a hanging station.
+type blockingTransport struct{}
+
+func (blockingTransport) Open() error { return nil }
+
+func (blockingTransport) Close() {}
+
+func (blockingTransport) Request(ctx context.Context, command byte, _ []byte, _ ...bool) ([]byte, error) {
+	<-ctx.Done()
+	return nil, fmt.Errorf("request 0x%02x aborted: %w", command, ctx.Err())
+}
+
+func TestRoomLogg_FetchCurrentDataContextCancel(t *testing.T) {
+	r := NewRoomLoggWithTransport(&RoomLoggConfig{PollingRate: 60}, blockingTransport{})
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	if _, err := r.FetchCurrentDataContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("FetchCurrentDataContext() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRoomLogg_RequestTimeout(t *testing.T) {
+	r := NewRoomLoggWithTransport(&RoomLoggConfig{PollingRate: 60, RequestTimeout: 1}, blockingTransport{})
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+
+	start := time.Now()
+	if err := r.SetLanguage(LanguageData(LanguageEN)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("SetLanguage() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("SetLanguage() took %v, want about %v", elapsed, time.Second)
+	}
+}
+
+func TestRoomLogg_ContextCancelWhileWaiting(t *testing.T) {
+	r := NewRoomLoggWithTransport(&RoomLoggConfig{PollingRate: 60}, blockingTransport{})
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+
+	// The first request occupies the transport until it is cancelled
+	busyCtx, busyCancel := context.WithCancel(context.Background())
+	busy := make(chan struct{})
+	go func() {
+		defer close(busy)
+		_, _ = r.FetchSettingsContext(busyCtx)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := r.FetchCurrentDataContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("FetchCurrentDataContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	busyCancel()
+	<-busy
+}
+
+// exclusiveTransport wraps the emulator and records overlapping requests and the order of all commands.
+type exclusiveTransport struct {
+	*Emulator
+
+	inFlight int32
+	overlaps int32
+
+	mux      sync.Mutex
+	commands []byte
+}
+
+func (e *exclusiveTransport) Request(ctx context.Context, command byte, payload []byte, noResponse ...bool) ([]byte, error) {
+	if atomic.AddInt32(&e.inFlight, 1) > 1 {
+		atomic.AddInt32(&e.overlaps, 1)
+	}
+	defer atomic.AddInt32(&e.inFlight, -1)
+
+	e.mux.Lock()
+	e.commands = append(e.commands, command)
+	e.mux.Unlock()
+
+	time.Sleep(50 * time.Microsecond) // give other goroutines the chance to interleave
+
+	return e.Emulator.Request(ctx, command, payload, noResponse...)
+}
+
+func TestRoomLogg_ConcurrentExchanges(t *testing.T) {
+	transport := &exclusiveTransport{Emulator: NewEmulator()}
+	r := NewRoomLoggWithTransport(&RoomLoggConfig{PollingRate: 60, SDCard: true}, transport)
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+
+	settings, err := r.FetchSettings()
+	if err != nil {
+		t.Fatalf("FetchSettings() error = %v", err)
+	}
+	calibration, err := r.FetchCalibrationData()
+	if err != nil {
+		t.Fatalf("FetchCalibrationData() error = %v", err)
+	}
+
+	operations := []func() error{
+		func() error { _, err := r.FetchCurrentData(); return err },
+		func() error { _, err := r.FetchSettings(); return err },
+		func() error { return r.SetSettings(settings) },
+		func() error { return r.SetLanguage(LanguageData(LanguageEN)) },
+		func() error { return r.SetCalibrationData(calibration) },
+		func() error { _, err := r.FetchSDCardHistory(); return err },
+		func() error { return r.Reconnect() },
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if err := operations[(i+j)%len(operations)](); err != nil {
+					t.Errorf("operation %d error = %v", (i+j)%len(operations), err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if overlaps := atomic.LoadInt32(&transport.overlaps); overlaps != 0 {
+		t.Errorf("ConcurrentExchanges() got %d overlapping requests, want 0", overlaps)
+	}
+
+	// Every store sequence must be sent as one block: start-store -> set -> end-store
+	commands := transport.commands
+	for i, command := range commands {
+		if command != CommandStartStore {
+			continue
+		}
+		if i+2 >= len(commands) || commands[i+2] != CommandEndStore {
+			t.Errorf("ConcurrentExchanges() store sequence at %d got = % x", i, commands[i:])
+			break
+		}
+	}
+}
+
+func TestRoomLogg_ConcurrentFetchAndClose(t *testing.T) {
+	r, _ := newEmulatedRoomLogg(t)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				_, _ = r.FetchCurrentData() // fails once the station is closed
+			}
+		}()
+	}
+	r.Close()
+	wg.Wait()
+}
+
+func TestRoomLogg_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(conn *internal.ScriptedConnection)
+		call    func(r *RoomLogg) error
+		want    error
+	}{
+		{
+			name:    "missing response",
+			prepare: func(conn *internal.ScriptedConnection) { addSettingsResponse(conn, UnitCelsius) },
+			call:    func(r *RoomLogg) error { _, err := r.FetchCurrentData(); return err },
+			want:    ErrTimeout,
+		},
+		{
+			name: "empty frame",
+			prepare: func(conn *internal.ScriptedConnection) {
+				addSettingsResponse(conn, UnitCelsius)
+				conn.AddRawResponse(CommandGetCurrentData, nil)
+			},
+			call: func(r *RoomLogg) error { _, err := r.FetchCurrentData(); return err },
+			want: ErrBadFrame,
+		},
+		{
+			name:    "missing message end",
+			prepare: func(conn *internal.ScriptedConnection) { conn.AddRawResponse(CommandGetSettings, []byte{0x7b, 0x01}) },
+			call:    func(r *RoomLogg) error { _, err := r.FetchSettings(); return err },
+			want:    ErrBadFrame,
+		},
+		{
+			name: "response of another command",
+			prepare: func(conn *internal.ScriptedConnection) {
+				addSettingsResponse(conn, UnitCelsius)
+				conn.AddResponse(CommandGetCurrentData, make([]byte, 27))
+			},
+			call: func(r *RoomLogg) error { _, err := r.FetchCurrentData(); return err },
+			want: ErrUnexpectedCommand,
+		},
+		{
+			name: "short file page",
+			prepare: func(conn *internal.ScriptedConnection) {
+				addSettingsResponse(conn, UnitCelsius)
+				conn.AddResponse(CommandGetSDCardFile, []byte{0x00})
+			},
+			call: func(r *RoomLogg) error { _, err := r.FetchSDCardHistory(); return err },
+			want: ErrBadFrame,
+		},
+		{
+			name: "partial history record",
+			prepare: func(conn *internal.ScriptedConnection) {
+				addSettingsResponse(conn, UnitCelsius)
+				conn.AddResponse(CommandGetSDCardFile, append([]byte{0x00, 0x01}, make([]byte, 20)...))
+			},
+			call: func(r *RoomLogg) error { _, err := r.FetchSDCardHistory(); return err },
+			want: ErrBadFrame,
+		},
+		{
+			name:    "store rejected",
+			prepare: func(conn *internal.ScriptedConnection) { conn.AddResponse(CommandStartStore, []byte{0x01}) },
+			call:    func(r *RoomLogg) error { return r.SetLanguage(LanguageData(LanguageEN)) },
+			want:    ErrStoreRejected,
+		},
+		{
+			name:    "disconnected",
+			prepare: func(conn *internal.ScriptedConnection) { conn.Close() },
+			call:    func(r *RoomLogg) error { _, err := r.FetchIntervalMinutes(); return err },
+			want:    ErrDisconnected,
+		},
+		{
+			name:    "invalid interval",
+			prepare: func(conn *internal.ScriptedConnection) {},
+			call:    func(r *RoomLogg) error { return r.SetIntervalMinutes(241) },
+			want:    ErrInvalidValue,
+		},
+		{
+			name:    "missing calibration channels",
+			prepare: func(conn *internal.ScriptedConnection) {},
+			call:    func(r *RoomLogg) error { return r.SetCalibrationData([]*CalibrationData{{}}) },
+			want:    ErrInvalidValue,
+		},
+		{
+			name:    "missing settings",
+			prepare: func(conn *internal.ScriptedConnection) {},
+			call:    func(r *RoomLogg) error { return r.SetSettings(nil) },
+			want:    ErrInvalidValue,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, conn := newScriptedRoomLogg(t)
+			defer r.Close()
+			r.cfg.SDCard = true
+
+			tt.prepare(conn)
+			if err := tt.call(r); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoomLogg_RequestError(t *testing.T) {
+	r, conn := newScriptedRoomLogg(t)
+	defer r.Close()
+
+	conn.AddResponse(CommandStartStore, []byte{0x01})
+
+	err := r.SetSettings(&SettingsData{})
+	var requestErr *RequestError
+	if !errors.As(err, &requestErr) {
+		t.Fatalf("SetSettings() error = %v, want RequestError", err)
+	}
+	if requestErr.Command != CommandStartStore {
+		t.Errorf("SetSettings() error command = 0x%02x, want 0x%02x", requestErr.Command, CommandStartStore)
+	}
+	if errors.Is(err, ErrTimeout) {
+		t.Errorf("SetSettings() error = %v, must not be %v", err, ErrTimeout)
+	}
+}
+
+func TestRoomLogg_TraceReplay(t *testing.T) {
+	file, err := os.Open(filepath.Join("testdata", "current-data.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	transport, err := NewTraceReplay(file)
+	if err != nil {
+		t.Fatalf("NewTraceReplay() error = %v", err)
+	}
+	r := NewRoomLoggWithTransport(&RoomLoggConfig{PollingRate: 60}, transport)
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+
+	settings, err := r.FetchSettings()
+	if err != nil {
+		t.Fatalf("FetchSettings() error = %v", err)
+	}
+	if settings.Units != UnitCelsius || settings.TimeZone != 1 || settings.DST != Flag(0x01) {
+		t.Errorf("FetchSettings() got = %+v", settings)
+	}
+
+	got, err := r.FetchCurrentData()
+	if err != nil {
+		t.Fatalf("FetchCurrentData() error = %v", err)
+	}
+	want := []*ChannelData{
+		{Number: 1, Temperature: 3.7, Humidity: 70},
+		{Number: 3, Temperature: -0.5, Humidity: 9},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FetchCurrentData() got = %v, want %v", got, want)
+	}
+}
+
+func TestNewRoomLoggStation_TraceFile(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &RoomLoggConfig{PollingRate: 60, Backend: BackendHidraw, TraceFile: filepath.Join(dir, "trace.jsonl")}
+
+	r, err := NewRoomLoggStation(cfg, "house", "1-2")
+	if err != nil {
+		t.Fatalf("NewRoomLoggStation() error = %v", err)
+	}
+	r.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, "trace-house.jsonl")); err != nil {
+		t.Errorf("NewRoomLoggStation() trace file error = %v", err)
+	}
+}
+
+func TestNewRoomLogg_InvalidConfig(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		cfg  *RoomLoggConfig
+	}{
+		{"device", &RoomLoggConfig{Backend: BackendHidraw, Device: "not a device"}},
+		{"backend", &RoomLoggConfig{Backend: "serial"}},
+		{"trace file", &RoomLoggConfig{Backend: BackendHidraw, TraceFile: filepath.Join(dir, "missing", "trace.jsonl")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRoomLogg(tt.cfg)
+			if err == nil {
+				t.Errorf("NewRoomLogg() = %v, want error", r)
+			}
+		})
+	}
+}
+
+func TestRoomLogg_FetchCurrentDataWithoutConfig(t *testing.T) {
+	conn := internal.NewScriptedConnection()
+	r := NewRoomLoggWithTransport(nil, conn)
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+
+	addSettingsResponse(conn, UnitFahrenheit)
+	conn.AddResponse(CommandGetCurrentData, []byte{0x00, 0xd5, 0x30, 0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff, 0x7f, 0xff,
+		0xff, 0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff})
+
+	got, err := r.FetchCurrentData()
+	if err != nil {
+		t.Fatalf("FetchCurrentData() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Unit != UnitFahrenheit || got[0].Info != nil {
+		t.Errorf("FetchCurrentData() got = %v", got)
+	}
+}
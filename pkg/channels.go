@@ -0,0 +1,121 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ChannelInfo describes the sensor of a channel and where it is placed.
+type ChannelInfo struct {
+	Name    string `yaml:"name"`
+	Room    string `yaml:"room"`
+	Floor   string `yaml:"floor"`
+	Outdoor bool   `yaml:"outdoor"`
+}
+
+// ChannelRegistry maps channels to their metadata. A key is either the channel number (e.g. 1), which applies to all
+// stations, or the station ID and the channel number (e.g. garage/1).
+type ChannelRegistry map[string]*ChannelInfo
+
+// Decode parses the registry from an environment variable. Channels are separated by commas, each channel consists of
+// the key, the name and optional attributes, e.g. 1:Living Room;room=Living Room;floor=Ground,garage/4:Garden;outdoor=true
+func (r *ChannelRegistry) Decode(value string) error {
+	registry := ChannelRegistry{}
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		key, attributes, ok := strings.Cut(entry, ":")
+		if !ok {
+			return fmt.Errorf("%w: channel %q has no name", ErrInvalidValue, entry)
+		}
+		key = strings.TrimSpace(key)
+		if err := validateChannelKey(key); err != nil {
+			return err
+		}
+
+		fields := strings.Split(attributes, ";")
+		info := &ChannelInfo{Name: strings.TrimSpace(fields[0])}
+		for _, field := range fields[1:] {
+			name, value, _ := strings.Cut(field, "=")
+			value = strings.TrimSpace(value)
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case "room":
+				info.Room = value
+			case "floor":
+				info.Floor = value
+			case "outdoor":
+				outdoor, err := strconv.ParseBool(value)
+				if err != nil {
+					return fmt.Errorf("%w: channel %s: outdoor %q is not a boolean", ErrInvalidValue, key, value)
+				}
+				info.Outdoor = outdoor
+			default:
+				return fmt.Errorf("%w: channel %s: unknown attribute %q", ErrInvalidValue, key, name)
+			}
+		}
+		registry[key] = info
+	}
+
+	*r = registry
+
+	return nil
+}
+
+// validateChannelKey checks that the key is a channel number from 1 to 8, optionally prefixed by a station ID.
+func validateChannelKey(key string) error {
+	number := key
+	if station, channel, ok := strings.Cut(key, "/"); ok {
+		if station == "" {
+			return fmt.Errorf("%w: channel %q has an empty station", ErrInvalidValue, key)
+		}
+		number = channel
+	}
+	if n, err := strconv.Atoi(number); err != nil || n < 1 || n > 8 {
+		return fmt.Errorf("%w: channel %q is not a channel number from 1 to 8", ErrInvalidValue, key)
+	}
+
+	return nil
+}
+
+// Lookup returns the metadata of the channel of the given station, nil if the channel is not configured.
+// A station specific entry takes precedence over an entry for all stations.
+func (r ChannelRegistry) Lookup(station string, channel int) *ChannelInfo {
+	if station != "" {
+		if info, ok := r[fmt.Sprintf("%s/%d", station, channel)]; ok {
+			return info
+		}
+	}
+
+	return r[strconv.Itoa(channel)]
+}
+
+// Slug returns the name in lower case with all other characters than letters and digits replaced by underscores,
+// so it can be used in MQTT topics.
+func (i *ChannelInfo) Slug() string {
+	slug := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '_'
+	}, i.Name)
+
+	return strings.Trim(slug, "_")
+}
+
+// Validate checks the channel keys, the registry may be read from a config file without going through Decode.
+func (r ChannelRegistry) Validate() error {
+	for key, info := range r {
+		if err := validateChannelKey(key); err != nil {
+			return err
+		}
+		if info == nil {
+			return fmt.Errorf("%w: channel %s has no name", ErrInvalidValue, key)
+		}
+	}
+
+	return nil
+}
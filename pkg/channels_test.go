@@ -0,0 +1,108 @@
+package pkg
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestChannelRegistry_Decode(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    ChannelRegistry
+		wantErr error
+	}{
+		{
+			name:  "Full",
+			value: "1:Living Room;room=Living Room;floor=Ground,garage/4:Garden;outdoor=true",
+			want: ChannelRegistry{
+				"1":        {Name: "Living Room", Room: "Living Room", Floor: "Ground"},
+				"garage/4": {Name: "Garden", Outdoor: true},
+			},
+		},
+		{name: "Empty", value: "", want: ChannelRegistry{}},
+		{name: "MissingName", value: "1", wantErr: ErrInvalidValue},
+		{name: "InvalidChannel", value: "9:Attic", wantErr: ErrInvalidValue},
+		{name: "InvalidOutdoor", value: "1:Garden;outdoor=maybe", wantErr: ErrInvalidValue},
+		{name: "UnknownAttribute", value: "1:Garden;color=green", wantErr: ErrInvalidValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ChannelRegistry
+			err := got.Decode(tt.value)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Decode() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelRegistry_Lookup(t *testing.T) {
+	registry := ChannelRegistry{
+		"1":        {Name: "Living Room"},
+		"garage/1": {Name: "Workbench"},
+	}
+	tests := []struct {
+		name    string
+		station string
+		channel int
+		want    *ChannelInfo
+	}{
+		{"AllStations", "house", 1, registry["1"]},
+		{"StationSpecific", "garage", 1, registry["garage/1"]},
+		{"DefaultStation", "", 1, registry["1"]},
+		{"NotConfigured", "garage", 2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := registry.Lookup(tt.station, tt.channel); got != tt.want {
+				t.Errorf("Lookup() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelInfo_Slug(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Living Room", "living_room"},
+		{"Küche (Süd)", "küche__süd"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &ChannelInfo{Name: tt.name}
+			if got := info.Slug(); got != tt.want {
+				t.Errorf("Slug() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoomLogg_ChannelInfo(t *testing.T) {
+	cfg := &RoomLoggConfig{PollingRate: 60, Emulate: true, Channels: ChannelRegistry{"house/2": {Name: "Kitchen"}}}
+	r, err := NewRoomLoggStation(cfg, "house", "")
+	if err != nil {
+		t.Fatalf("NewRoomLoggStation() error = %v", err)
+	}
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+
+	got, err := r.FetchCurrentData()
+	if err != nil {
+		t.Fatalf("FetchCurrentData() error = %v", err)
+	}
+	for _, channel := range got {
+		if want := cfg.Channels.Lookup("house", channel.Number); channel.Info != want {
+			t.Errorf("FetchCurrentData() channel %d info = %v, want %v", channel.Number, channel.Info, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package pkg
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// timeSyncDelay is the delay between a DST transition and the following time sync.
+const timeSyncDelay = time.Minute
+
+// clockNow returns the host time for SyncTime, tests replace it to sync across a DST transition.
+var clockNow = time.Now
+
+// SyncTime sets the station clock to the host time in the configured timezone. The DST flag and the timezone of the
+// station settings are updated as well, if they do not match the timezone. Reading and writing the settings and
+// setting the clock is one exchange, no other caller can change the settings in between.
+func (r *RoomLogg) SyncTime() error {
+	return r.SyncTimeContext(context.Background())
+}
+
+// SyncTimeContext is like SyncTime but aborts once the context is done.
+func (r *RoomLogg) SyncTimeContext(ctx context.Context) error {
+	loc, err := r.location()
+	if err != nil {
+		return err
+	}
+
+	if err := r.tryLock(ctx); err != nil {
+		return err
+	}
+	defer r.unlock()
+
+	settings, err := r.fetchSettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	dst, zone := zoneSettings(clockNow().In(loc))
+	if settings.DST != dst || settings.TimeZone != zone {
+		logrus.Infof("Updating DST flag (%d -> %d) and timezone (%d -> %d) of DNT RoomLogg PRO %q", settings.DST,
+			dst, settings.TimeZone, zone, r.id)
+		settings.DST = dst
+		settings.TimeZone = zone
+		if err := r.setSettings(ctx, settings); err != nil {
+			return err
+		}
+	}
+
+	return r.setTime(ctx, NewTimeDataFromTime(clockNow().In(loc)))
+}
+
+// location returns the timezone of the station clock, the host timezone if no config is set.
+func (r *RoomLogg) location() (*time.Location, error) {
+	if r.cfg == nil {
+		return time.Local, nil
+	}
+
+	return r.cfg.Location()
+}
+
+// zoneSettings returns the DST flag and the standard UTC offset in hours for the station settings. The station only
+// supports whole hours, other offsets are truncated.
+func zoneSettings(t time.Time) (Flag, int8) {
+	_, offset := t.Zone()
+	dst := DSTOff
+	if t.IsDST() {
+		dst = DSTOn
+		offset -= 60 * 60 // the station adds one hour if DST is enabled
+	}
+	if offset%(60*60) != 0 {
+		logrus.Warnf("Timezone %s has an UTC offset of %v, DNT RoomLogg PRO only supports whole hours", t.Location(),
+			time.Duration(offset)*time.Second)
+	}
+
+	return dst, int8(offset / (60 * 60))
+}
+
+// NextTimeSync returns when the station clock should be synced next: after the interval, or shortly after the next
+// change of the UTC offset (DST transition) if that comes first.
+func NextTimeSync(loc *time.Location, now time.Time, interval time.Duration) time.Time {
+	next := now.Add(interval)
+	if transition, ok := nextZoneTransition(loc, now, next); ok && transition.Add(timeSyncDelay).Before(next) {
+		return transition.Add(timeSyncDelay)
+	}
+
+	return next
+}
+
+// nextZoneTransition returns the first instant after from and not after until with a different UTC offset than from.
+func nextZoneTransition(loc *time.Location, from, until time.Time) (time.Time, bool) {
+	_, offset := from.In(loc).Zone()
+	for start := from; start.Before(until); start = start.Add(time.Hour) {
+		end := start.Add(time.Hour)
+		if end.After(until) {
+			end = until
+		}
+		if _, endOffset := end.In(loc).Zone(); endOffset == offset {
+			continue
+		}
+
+		// The offset changes within this hour, find the exact second
+		for end.Sub(start) > time.Second {
+			middle := start.Add(end.Sub(start) / 2)
+			if _, middleOffset := middle.In(loc).Zone(); middleOffset == offset {
+				start = middle
+			} else {
+				end = middle
+			}
+		}
+		return end.Truncate(time.Second), true
+	}
+
+	return time.Time{}, false
+}
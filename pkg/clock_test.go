@@ -0,0 +1,286 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/h44z/dntroomloggpro-go/internal"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return loc
+}
+
+func Test_zoneSettings(t *testing.T) {
+	vienna := mustLoadLocation(t, "Europe/Vienna")
+	newYork := mustLoadLocation(t, "America/New_York")
+	tests := []struct {
+		name     string
+		time     time.Time
+		wantDST  Flag
+		wantZone int8
+	}{
+		{"Winter", time.Date(2022, 1, 15, 12, 0, 0, 0, vienna), DSTOff, 1},
+		{"Summer", time.Date(2022, 7, 15, 12, 0, 0, 0, vienna), DSTOn, 1},
+		{"NegativeSummer", time.Date(2022, 7, 15, 12, 0, 0, 0, newYork), DSTOn, -5},
+		{"UTC", time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC), DSTOff, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotDST, gotZone := zoneSettings(tt.time)
+			if gotDST != tt.wantDST || gotZone != tt.wantZone {
+				t.Errorf("zoneSettings() got = %v, %v, want %v, %v", gotDST, gotZone, tt.wantDST, tt.wantZone)
+			}
+		})
+	}
+}
+
+func TestNextTimeSync(t *testing.T) {
+	vienna := mustLoadLocation(t, "Europe/Vienna")
+	tests := []struct {
+		name     string
+		now      time.Time
+		interval time.Duration
+		want     time.Time
+	}{
+		{
+			name:     "NoTransition",
+			now:      time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC),
+			interval: 24 * time.Hour,
+			want:     time.Date(2022, 7, 16, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "SpringForward",
+			now:      time.Date(2022, 3, 26, 12, 0, 0, 0, time.UTC),
+			interval: 24 * time.Hour,
+			want:     time.Date(2022, 3, 27, 1, 1, 0, 0, time.UTC),
+		},
+		{
+			name:     "FallBack",
+			now:      time.Date(2022, 10, 29, 12, 30, 15, 500, time.UTC),
+			interval: 24 * time.Hour,
+			want:     time.Date(2022, 10, 30, 1, 1, 0, 0, time.UTC),
+		},
+		{
+			name:     "TransitionAfterInterval",
+			now:      time.Date(2022, 3, 26, 12, 0, 0, 0, time.UTC),
+			interval: time.Hour,
+			want:     time.Date(2022, 3, 26, 13, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NextTimeSync(vienna, tt.now, tt.interval); !got.Equal(tt.want) {
+				t.Errorf("NextTimeSync() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoomLogg_SyncTime(t *testing.T) {
+	e := NewEmulator()
+	r := NewRoomLoggWithTransport(&RoomLoggConfig{PollingRate: 60, TimeZone: "America/New_York", GetTime: true}, e)
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+
+	if err := r.SyncTime(); err != nil {
+		t.Fatalf("SyncTime() error = %v", err)
+	}
+
+	settings, err := r.FetchSettings()
+	if err != nil {
+		t.Fatalf("FetchSettings() error = %v", err)
+	}
+	wantDST, wantZone := zoneSettings(time.Now().In(mustLoadLocation(t, "America/New_York")))
+	if settings.DST != wantDST || settings.TimeZone != wantZone {
+		t.Errorf("SyncTime() settings = %v, %v, want %v, %v", settings.DST, settings.TimeZone, wantDST, wantZone)
+	}
+
+	drift, err := r.FetchClockDrift()
+	if err != nil {
+		t.Fatalf("FetchClockDrift() error = %v", err)
+	}
+	if drift.Drift < -time.Second || drift.Drift > time.Second {
+		t.Errorf("SyncTime() drift = %v, want less than %v", drift.Drift, time.Second)
+	}
+}
+
+func TestRoomLogg_SyncTimeInvalidZone(t *testing.T) {
+	r := NewRoomLoggWithTransport(&RoomLoggConfig{PollingRate: 60, TimeZone: "Mars/Olympus_Mons"}, NewEmulator())
+	if err := r.SyncTime(); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("SyncTime() error = %v, want %v", err, ErrInvalidValue)
+	}
+}
+
+func TestRoomLogg_RecordTimezone(t *testing.T) {
+	zone := "Pacific/Kiritimati" // UTC+14, never the host timezone of a test run
+	if time.Local.String() == zone {
+		zone = "America/New_York"
+	}
+	loc := mustLoadLocation(t, zone)
+
+	conn := internal.NewScriptedConnection()
+	r := NewRoomLoggWithTransport(&RoomLoggConfig{PollingRate: 60, TimeZone: zone, SDCard: true, ExportFile: true}, conn)
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+
+	recorded := time.Date(2022, 8, 1, 10, 0, 0, 0, loc)
+	history := &HistoryRecord{Time: recorded, Channels: []*ChannelData{{Number: 1, Temperature: 21.3, Humidity: 48}}}
+	export := ExportData{{Time: recorded, Channel: 1, Temperature: 21.3, Humidity: 48}}
+	addSettingsResponse(conn, UnitCelsius)
+	conn.AddResponse(CommandGetSDCardFile, (&FilePageData{Pages: 1, Records: history.RawBytes()}).RawBytes())
+	conn.AddResponse(CommandGetExportFile, (&FilePageData{Pages: 1, Records: export.RawBytes()}).RawBytes())
+
+	records, err := r.FetchSDCardHistory()
+	if err != nil {
+		t.Fatalf("FetchSDCardHistory() error = %v", err)
+	}
+	if len(records) != 1 || !records[0].Time.Equal(recorded) || records[0].Time.Location().String() != zone {
+		t.Errorf("FetchSDCardHistory() time = %v, want %v", records[0].Time, recorded)
+	}
+
+	exported, err := r.FetchExportFile()
+	if err != nil {
+		t.Fatalf("FetchExportFile() error = %v", err)
+	}
+	if len(exported) != 1 || !exported[0].Time.Equal(recorded) {
+		t.Errorf("FetchExportFile() time = %v, want %v", exported[0].Time, recorded)
+	}
+}
+
+// hookTransport calls before ahead of each request of the wrapped transport.
+type hookTransport struct {
+	Transport
+	before func(command byte)
+}
+
+func (t *hookTransport) Request(ctx context.Context, command byte, payload []byte, noResponse ...bool) ([]byte, error) {
+	t.before(command)
+	return t.Transport.Request(ctx, command, payload, noResponse...)
+}
+
+func TestRoomLogg_SyncTimeConcurrentSetSettings(t *testing.T) {
+	var r *RoomLogg
+	var mux sync.Mutex
+	var commands []byte
+	concurrent := make(chan error, 1)
+	var once sync.Once
+
+	transport := &hookTransport{Transport: NewEmulator(), before: func(command byte) {
+		mux.Lock()
+		commands = append(commands, command)
+		mux.Unlock()
+
+		if command != CommandGetSettings {
+			return
+		}
+		once.Do(func() { // change the settings while the sync runs
+			go func() {
+				settings, _ := NewSettingsData(make([]byte, settingsSize))
+				concurrent <- r.SetSettings(settings)
+			}()
+			time.Sleep(50 * time.Millisecond)
+		})
+	}}
+	r = NewRoomLoggWithTransport(&RoomLoggConfig{PollingRate: 60, TimeZone: "Asia/Tokyo"}, transport)
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+
+	if err := r.SyncTime(); err != nil {
+		t.Fatalf("SyncTime() error = %v", err)
+	}
+	if err := <-concurrent; err != nil {
+		t.Fatalf("SetSettings() error = %v", err)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	want := []byte{
+		CommandGetSettings, CommandStartStore, CommandSetSettings, CommandEndStore, CommandStartStore, CommandSetTime,
+		CommandStartStore, CommandSetSettings, CommandEndStore, // the concurrent SetSettings runs after the sync
+	}
+	if !reflect.DeepEqual(commands, want) {
+		t.Errorf("SyncTime() commands = % x, want % x", commands, want)
+	}
+}
+
+// emulatorPayload returns the unframed response payload of the emulator for the command.
+func emulatorPayload(t *testing.T, e *Emulator, command byte) []byte {
+	t.Helper()
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	payload, err := e.handle(command, nil)
+	if err != nil {
+		t.Fatalf("handle() error = %v", err)
+	}
+
+	return payload
+}
+
+func TestRoomLogg_SyncTimeAcrossDST(t *testing.T) {
+	vienna := mustLoadLocation(t, "Europe/Vienna")
+	tests := []struct {
+		name       string
+		now        time.Time
+		wantDST    Flag
+		wantZone   int8
+		wantOffset int
+	}{
+		{"winter", time.Date(2022, 3, 27, 1, 30, 0, 0, vienna), DSTOff, 1, 60 * 60},
+		{"summer", time.Date(2022, 3, 27, 3, 30, 0, 0, vienna), DSTOn, 1, 2 * 60 * 60},
+		{"back to winter", time.Date(2022, 10, 30, 3, 30, 0, 0, vienna), DSTOff, 1, 60 * 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clockNow = func() time.Time { return tt.now }
+			defer func() { clockNow = time.Now }()
+
+			r, e := newEmulatedRoomLogg(t)
+			defer r.Close()
+			r.cfg.TimeZone = "Europe/Vienna"
+			r.cfg.GetTime = true
+
+			if err := r.SyncTime(); err != nil {
+				t.Fatalf("SyncTime() error = %v", err)
+			}
+
+			settings := emulatorPayload(t, e, CommandGetSettings)
+			if Flag(settings[4]) != tt.wantDST || int8(settings[5]) != tt.wantZone {
+				t.Errorf("SyncTime() settings frame DST = %v, timezone = %v, want %v, %v", settings[4], settings[5],
+					tt.wantDST, tt.wantZone)
+			}
+			clock := emulatorPayload(t, e, CommandGetTime)
+			if int8(clock[7]) != tt.wantZone || int(clock[4]) != tt.now.Hour() {
+				t.Errorf("SyncTime() time frame timezone = %v, hour = %v, want %v, %v", clock[7], clock[4],
+					tt.wantZone, tt.now.Hour())
+			}
+
+			got, err := r.FetchTime()
+			if err != nil {
+				t.Fatalf("FetchTime() error = %v", err)
+			}
+			if _, offset := got.Time().Zone(); offset != tt.wantOffset || got.Time().Sub(tt.now) > time.Minute {
+				t.Errorf("FetchTime() got = %v, want %v", got.Time(), tt.now)
+			}
+		})
+	}
+}
@@ -1,93 +1,407 @@
 package pkg
 
 import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
 	"github.com/kelseyhightower/envconfig"
-	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
 )
 
-type RoomLoggConfig struct {
-	PollingRate int `envconfig:"POLLING_RATE"` // Seconds
+// Config is the complete configuration of the logger. The values are read from an optional YAML config file,
+// environment variables override the values of the file.
+type Config struct {
+	RoomLogg RoomLoggConfig `yaml:"roomlogg"`
+	Features FeatureConfig  `yaml:"features"`
+	Rest     RestConfig     `yaml:"rest"`
+	Mqtt     MqttConfig     `yaml:"mqtt"`
+	Influx   InfluxConfig   `yaml:"influx"`
+}
+
+// LoadConfig loads the defaults, the config file at the given path and the environment, in this order. The path may
+// be empty if no config file is used. The configuration of disabled publishers is not validated.
+func LoadConfig(path string) (*Config, error) {
+	cfg := defaultConfig()
+	if path != "" {
+		if err := loadConfigFile(path, cfg); err != nil {
+			return nil, err
+		}
+	}
+	for _, section := range []any{&cfg.RoomLogg, &cfg.Features, &cfg.Rest, &cfg.Mqtt, &cfg.Influx} {
+		if err := loadConfigEnv(section); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		RoomLogg: *defaultRoomLoggConfig(),
+		Features: *defaultFeatureConfig(),
+		Rest:     *defaultRestConfig(),
+		Mqtt:     *defaultMqttConfig(),
+		Influx:   *defaultInfluxConfig(),
+	}
 }
 
-func NewRoomLoggConfig() *RoomLoggConfig {
-	// Default config
-	cfg := &RoomLoggConfig{
-		PollingRate: 60, // 1 Minute
+// Validate checks the station config and the config of all enabled publishers.
+func (c *Config) Validate() error {
+	if err := c.RoomLogg.Validate(); err != nil {
+		return err
+	}
+	if c.Features.Rest {
+		if err := c.Rest.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Features.Mqtt {
+		if err := c.Mqtt.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Features.Influx {
+		if err := c.Influx.Validate(); err != nil {
+			return err
+		}
 	}
+
+	return nil
+}
+
+type RoomLoggConfig struct {
+	PollingRate      int               `envconfig:"POLLING_RATE" yaml:"polling_rate"`                         // Seconds
+	MonitorInterval  int               `envconfig:"MONITOR_INTERVAL" yaml:"monitor_interval"`                 // Seconds between USB device checks, 0 disables hotplug detection
+	RequestTimeout   int               `envconfig:"REQUEST_TIMEOUT" yaml:"request_timeout"`                   // Seconds until a request to the station is aborted, 0 disables the timeout
+	Emulate          bool              `envconfig:"EMULATE" yaml:"emulate"`                                   // Use an emulated base station instead of the USB device
+	Backend          string            `envconfig:"BACKEND" yaml:"backend"`                                   // USB backend, libusb or hidraw
+	Device           string            `envconfig:"DEVICE" yaml:"device"`                                     // Device selector (bus.address or port path), empty for any device
+	Stations         map[string]string `envconfig:"STATIONS" yaml:"stations"`                                 // Station ID to device selector, e.g. house:1-1,garage:1-2
+	TraceFile        string            `envconfig:"TRACE_FILE" yaml:"trace_file"`                             // Records all exchanges with the station to this file
+	TimeZone         string            `envconfig:"TIMEZONE" yaml:"timezone"`                                 // IANA timezone of the station clock, e.g. Europe/Vienna, empty for the host timezone
+	TimeSyncInterval int               `envconfig:"TIME_SYNC_INTERVAL" yaml:"time_sync_interval"`             // Seconds between station clock syncs, 0 disables the sync
+	GetTime          bool              `envconfig:"EXPERIMENTAL_GET_TIME" yaml:"experimental_get_time"`       // Enables the unverified get-time command (FetchTime, FetchClockDrift)
+	SDCard           bool              `envconfig:"EXPERIMENTAL_SD_CARD" yaml:"experimental_sd_card"`         // Enables the unverified SD card file download (FetchSDCardHistory)
+	ExportFile       bool              `envconfig:"EXPERIMENTAL_EXPORT_FILE" yaml:"experimental_export_file"` // Enables the unverified export file download (FetchExportFile)
+	TemperatureUnit  string            `envconfig:"TEMPERATURE_UNIT" yaml:"temperature_unit"`                 // Converts current data and file records to celsius or fahrenheit, empty for the station unit
+	Channels         ChannelRegistry   `envconfig:"CHANNELS" yaml:"channels"`                                 // Channel metadata, e.g. 1:Living Room;room=Living Room;floor=Ground
+	Alarms           AlarmRegistry     `envconfig:"ALARMS" yaml:"alarms"`                                     // Alarm thresholds written to the station, e.g. 1:temperature=18..25;humidity=40..60
+}
+
+func NewRoomLoggConfig() (*RoomLoggConfig, error) {
+	cfg := defaultRoomLoggConfig()
 	if err := loadConfigEnv(cfg); err != nil {
-		logrus.Warnf("unable to load environment config: %v", err)
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 
-	return cfg
+	return cfg, nil
+}
+
+func defaultRoomLoggConfig() *RoomLoggConfig {
+	return &RoomLoggConfig{
+		PollingRate:      60, // 1 Minute
+		MonitorInterval:  2,
+		RequestTimeout:   5,
+		Backend:          BackendLibusb,
+		TimeSyncInterval: 24 * 60 * 60, // 1 Day
+	}
+}
+
+// Validate checks that all values are within their limits.
+func (c *RoomLoggConfig) Validate() error {
+	if c.PollingRate <= 0 {
+		return fmt.Errorf("%w: polling rate %d must be positive", ErrInvalidValue, c.PollingRate)
+	}
+	for name, value := range map[string]int{
+		"monitor interval":   c.MonitorInterval,
+		"request timeout":    c.RequestTimeout,
+		"time sync interval": c.TimeSyncInterval,
+	} {
+		if value < 0 {
+			return fmt.Errorf("%w: %s %d must not be negative", ErrInvalidValue, name, value)
+		}
+	}
+	if c.Backend != BackendLibusb && c.Backend != BackendHidraw {
+		return fmt.Errorf("%w: backend %q, want %s or %s", ErrInvalidValue, c.Backend, BackendLibusb, BackendHidraw)
+	}
+	for id := range c.Stations {
+		if id == "" {
+			return fmt.Errorf("%w: station with an empty ID", ErrInvalidValue)
+		}
+	}
+	if _, err := c.Location(); err != nil {
+		return err
+	}
+	if _, _, err := c.CanonicalUnit(); err != nil {
+		return err
+	}
+	if err := c.Channels.Validate(); err != nil {
+		return err
+	}
+	if err := c.Alarms.Validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Location returns the configured timezone of the station clock, the host timezone if none is configured.
+func (c *RoomLoggConfig) Location() (*time.Location, error) {
+	if c.TimeZone == "" {
+		return time.Local, nil
+	}
+
+	loc, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: timezone %q: %v", ErrInvalidValue, c.TimeZone, err)
+	}
+
+	return loc, nil
+}
+
+// CanonicalUnit returns the temperature unit that current data is converted to. The flag is false if the data is
+// reported in the unit of the station.
+func (c *RoomLoggConfig) CanonicalUnit() (Unit, bool, error) {
+	if c.TemperatureUnit == "" {
+		return 0, false, nil
+	}
+
+	unit, err := ParseUnit(c.TemperatureUnit)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return unit, true, nil
+}
+
+// FeatureConfig enables the publishers of the logger.
+type FeatureConfig struct {
+	Rest   bool `envconfig:"ENABLE_REST" yaml:"rest"`
+	Mqtt   bool `envconfig:"ENABLE_MQTT" yaml:"mqtt"`
+	Influx bool `envconfig:"ENABLE_INFLUX" yaml:"influx"`
+}
+
+func defaultFeatureConfig() *FeatureConfig {
+	return &FeatureConfig{
+		Rest:   true,
+		Mqtt:   true,
+		Influx: true,
+	}
 }
 
 type InfluxConfig struct {
-	URL      string `envconfig:"INFLUX_URL"`
-	UserName string `envconfig:"INFLUX_USER"`
-	Password string `envconfig:"INFLUX_PASS"`
-	Bucket   string `envconfig:"INFLUX_BUCKET"`
+	URL      string `envconfig:"INFLUX_URL" yaml:"url"`
+	UserName string `envconfig:"INFLUX_USER" yaml:"user"`
+	Password string `envconfig:"INFLUX_PASS" yaml:"password"`
+	Bucket   string `envconfig:"INFLUX_BUCKET" yaml:"bucket"`
+
+	QueueSize int            `envconfig:"INFLUX_QUEUE_SIZE" yaml:"queue_size"` // Readings that wait to be published
+	Timeout   int            `envconfig:"INFLUX_TIMEOUT" yaml:"timeout"`       // Seconds until a publish is aborted
+	Overflow  OverflowPolicy `envconfig:"INFLUX_OVERFLOW" yaml:"overflow"`     // Drops the oldest or newest reading if the queue is full
+
+	SpoolDir     string `envconfig:"INFLUX_SPOOL_DIR" yaml:"spool_dir"`           // Keeps readings on disk while the server is down, empty disables the spool
+	SpoolMaxSize int    `envconfig:"INFLUX_SPOOL_MAX_SIZE" yaml:"spool_max_size"` // Megabytes of spooled readings, the oldest readings are dropped first
+	SpoolMaxAge  int    `envconfig:"INFLUX_SPOOL_MAX_AGE" yaml:"spool_max_age"`   // Seconds until a spooled reading is dropped
+}
+
+func NewInfluxConfig() (*InfluxConfig, error) {
+	cfg := defaultInfluxConfig()
+	if err := loadConfigEnv(cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
 }
 
-func NewInfluxConfig() *InfluxConfig {
-	// Default config
-	cfg := &InfluxConfig{
+func defaultInfluxConfig() *InfluxConfig {
+	return &InfluxConfig{
 		URL:      "http://localhost:8086",
 		UserName: "influxuser",
 		Password: "influxpass",
 		Bucket:   "roomlogg",
+
+		QueueSize: 10,
+		Timeout:   10,
+		Overflow:  OverflowDropOldest,
+
+		SpoolMaxSize: 10,
+		SpoolMaxAge:  7 * 24 * 60 * 60,
 	}
-	if err := loadConfigEnv(cfg); err != nil {
-		logrus.Warnf("unable to load environment config: %v", err)
+}
+
+// Validate checks that the URL is absolute, a bucket is set and the queue and spool settings are valid.
+func (c *InfluxConfig) Validate() error {
+	if u, err := url.Parse(c.URL); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%w: influx url %q is not an absolute URL", ErrInvalidValue, c.URL)
+	}
+	if c.Bucket == "" {
+		return fmt.Errorf("%w: influx bucket is empty", ErrInvalidValue)
+	}
+
+	if err := validateQueue("influx", c.QueueSize, c.Timeout, c.Overflow); err != nil {
+		return err
 	}
 
-	return cfg
+	return validateSpool("influx", c.SpoolMaxSize, c.SpoolMaxAge)
+}
+
+// Queue returns the queue options of the publisher.
+func (c *InfluxConfig) Queue() QueueOptions {
+	opts := newQueueOptions(c.QueueSize, c.Timeout, c.Overflow)
+	opts.Spool = newSpoolOptions(c.SpoolDir, c.SpoolMaxSize, c.SpoolMaxAge)
+
+	return opts
 }
 
 type RestConfig struct {
-	ListenAddress string `envconfig:"RESTAPI_ADDRESS"`
+	ListenAddress string `envconfig:"RESTAPI_ADDRESS" yaml:"listen_address"`
+
+	QueueSize int            `envconfig:"RESTAPI_QUEUE_SIZE" yaml:"queue_size"` // Readings that wait to be published
+	Timeout   int            `envconfig:"RESTAPI_TIMEOUT" yaml:"timeout"`       // Seconds until a publish is aborted
+	Overflow  OverflowPolicy `envconfig:"RESTAPI_OVERFLOW" yaml:"overflow"`     // Drops the oldest or newest reading if the queue is full
 }
 
-func NewRestConfig() *RestConfig {
-	// Default config
-	cfg := &RestConfig{
+func NewRestConfig() (*RestConfig, error) {
+	cfg := defaultRestConfig()
+	if err := loadConfigEnv(cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func defaultRestConfig() *RestConfig {
+	return &RestConfig{
 		ListenAddress: ":8080",
+
+		QueueSize: 1, // only the latest reading is served
+		Timeout:   5,
+		Overflow:  OverflowDropOldest,
 	}
-	if err := loadConfigEnv(cfg); err != nil {
-		logrus.Warnf("unable to load environment config: %v", err)
+}
+
+// Validate checks that the listen address consists of an optional host and a port and the queue settings are valid.
+func (c *RestConfig) Validate() error {
+	if _, _, err := net.SplitHostPort(c.ListenAddress); err != nil {
+		return fmt.Errorf("%w: rest listen address %q: %v", ErrInvalidValue, c.ListenAddress, err)
 	}
 
-	return cfg
+	return validateQueue("rest", c.QueueSize, c.Timeout, c.Overflow)
+}
+
+// Queue returns the queue options of the publisher.
+func (c *RestConfig) Queue() QueueOptions {
+	return newQueueOptions(c.QueueSize, c.Timeout, c.Overflow)
 }
 
 type MqttConfig struct {
-	Broker   string `envconfig:"MQTT_BROKER"`
-	Port     int    `envconfig:"MQTT_PORT"`
-	Username string `envconfig:"MQTT_USER"`
-	Password string `envconfig:"MQTT_PASS"`
+	Broker   string `envconfig:"MQTT_BROKER" yaml:"broker"`
+	Port     int    `envconfig:"MQTT_PORT" yaml:"port"`
+	Username string `envconfig:"MQTT_USER" yaml:"user"`
+	Password string `envconfig:"MQTT_PASS" yaml:"password"`
+
+	Topic string `envconfig:"MQTT_TOPIC" yaml:"topic"`
+
+	QueueSize int            `envconfig:"MQTT_QUEUE_SIZE" yaml:"queue_size"` // Readings that wait to be published
+	Timeout   int            `envconfig:"MQTT_TIMEOUT" yaml:"timeout"`       // Seconds until a publish is aborted
+	Overflow  OverflowPolicy `envconfig:"MQTT_OVERFLOW" yaml:"overflow"`     // Drops the oldest or newest reading if the queue is full
+
+	SpoolDir     string `envconfig:"MQTT_SPOOL_DIR" yaml:"spool_dir"`           // Keeps readings on disk while the broker is down, empty disables the spool
+	SpoolMaxSize int    `envconfig:"MQTT_SPOOL_MAX_SIZE" yaml:"spool_max_size"` // Megabytes of spooled readings, the oldest readings are dropped first
+	SpoolMaxAge  int    `envconfig:"MQTT_SPOOL_MAX_AGE" yaml:"spool_max_age"`   // Seconds until a spooled reading is dropped
+}
+
+func NewMqttConfig() (*MqttConfig, error) {
+	cfg := defaultMqttConfig()
+	if err := loadConfigEnv(cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 
-	Topic string `envconfig:"MQTT_TOPIC"`
+	return cfg, nil
 }
 
-func NewMqttConfig() *MqttConfig {
-	// Default config
-	cfg := &MqttConfig{
+func defaultMqttConfig() *MqttConfig {
+	return &MqttConfig{
 		Broker:   "localhost",
 		Port:     1883,
 		Username: "mqttUser",
 		Password: "mqttPassword",
 		Topic:    "roomlogg",
+
+		QueueSize: 10,
+		Timeout:   30, // includes waiting for home assistant to process the discovery
+		Overflow:  OverflowDropOldest,
+
+		SpoolMaxSize: 10,
+		SpoolMaxAge:  7 * 24 * 60 * 60,
 	}
-	if err := loadConfigEnv(cfg); err != nil {
-		logrus.Warnf("unable to load environment config: %v", err)
+}
+
+// Validate checks that a broker and a topic are set and the port, the queue and the spool settings are valid.
+func (c *MqttConfig) Validate() error {
+	if c.Broker == "" {
+		return fmt.Errorf("%w: mqtt broker is empty", ErrInvalidValue)
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return fmt.Errorf("%w: mqtt port %d is not within 1..65535", ErrInvalidValue, c.Port)
+	}
+	if c.Topic == "" {
+		return fmt.Errorf("%w: mqtt topic is empty", ErrInvalidValue)
 	}
 
-	return cfg
+	if err := validateQueue("mqtt", c.QueueSize, c.Timeout, c.Overflow); err != nil {
+		return err
+	}
+
+	return validateSpool("mqtt", c.SpoolMaxSize, c.SpoolMaxAge)
+}
+
+// Queue returns the queue options of the publisher.
+func (c *MqttConfig) Queue() QueueOptions {
+	opts := newQueueOptions(c.QueueSize, c.Timeout, c.Overflow)
+	opts.Spool = newSpoolOptions(c.SpoolDir, c.SpoolMaxSize, c.SpoolMaxAge)
+
+	return opts
+}
+
+// loadConfigFile reads the YAML config file into cfg, unknown keys are rejected.
+func loadConfigFile(path string, cfg *Config) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := yaml.UnmarshalStrict(raw, cfg); err != nil {
+		return fmt.Errorf("%w: config file %s: %v", ErrInvalidValue, path, err)
+	}
+
+	return nil
 }
 
 func loadConfigEnv(cfg any) error {
 	err := envconfig.Process("", cfg)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %v", ErrInvalidValue, err)
 	}
 
 	return nil
@@ -0,0 +1,160 @@
+package pkg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "roomlogg.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		want func(cfg *Config)
+	}{
+		{
+			name: "Defaults",
+			want: func(cfg *Config) {},
+		},
+		{
+			name: "File",
+			file: `
+roomlogg:
+  polling_rate: 30
+  stations: {house: 1-1}
+  channels:
+    1: {name: Living Room, floor: Ground}
+  alarms:
+    garage/2:
+      humidity: {low: 40, high: 60}
+features: {influx: false}
+mqtt: {broker: 10.10.10.10, topic: rl7}
+`,
+			want: func(cfg *Config) {
+				cfg.RoomLogg.PollingRate = 30
+				cfg.RoomLogg.Stations = map[string]string{"house": "1-1"}
+				cfg.RoomLogg.Channels = ChannelRegistry{"1": {Name: "Living Room", Floor: "Ground"}}
+				cfg.RoomLogg.Alarms = AlarmRegistry{"garage/2": {Humidity: &AlarmRange{Low: 40, High: 60}}}
+				cfg.Features.Influx = false
+				cfg.Mqtt.Broker = "10.10.10.10"
+				cfg.Mqtt.Topic = "rl7"
+			},
+		},
+		{
+			name: "EnvOverridesFile",
+			file: "roomlogg: {polling_rate: 30}\nmqtt: {topic: rl7}\n",
+			env:  map[string]string{"POLLING_RATE": "120", "ENABLE_REST": "false", "CHANNELS": "2:Kitchen"},
+			want: func(cfg *Config) {
+				cfg.RoomLogg.PollingRate = 120
+				cfg.RoomLogg.Channels = ChannelRegistry{"2": {Name: "Kitchen"}}
+				cfg.Features.Rest = false
+				cfg.Mqtt.Topic = "rl7"
+			},
+		},
+		{
+			name: "DisabledPublisherNotValidated",
+			file: "features: {mqtt: false}\nmqtt: {port: 0}\n",
+			want: func(cfg *Config) {
+				cfg.Features.Mqtt = false
+				cfg.Mqtt.Port = 0
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			path := ""
+			if tt.file != "" {
+				path = writeConfigFile(t, tt.file)
+			}
+
+			got, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			want := defaultConfig()
+			tt.want(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("LoadConfig() got = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+	}{
+		{name: "UnknownKey", file: "roomlogg: {poling_rate: 30}\n"},
+		{name: "Syntax", file: "roomlogg: [\n"},
+		{name: "PollingRate", file: "roomlogg: {polling_rate: 0}\n"},
+		{name: "Backend", file: "roomlogg: {backend: serial}\n"},
+		{name: "TimeZone", file: "roomlogg: {timezone: Mars/Olympus_Mons}\n"},
+		{name: "TemperatureUnit", file: "roomlogg: {temperature_unit: kelvin}\n"},
+		{name: "ChannelKey", file: "roomlogg: {channels: {9: {name: Attic}}}\n"},
+		{name: "AlarmRange", file: "roomlogg: {alarms: {1: {humidity: {low: 60, high: 40}}}}\n"},
+		{name: "MqttPort", file: "mqtt: {port: 70000}\n"},
+		{name: "InfluxURL", file: "influx: {url: localhost}\n"},
+		{name: "RestAddress", file: "rest: {listen_address: localhost}\n"},
+		{name: "QueueSize", file: "influx: {queue_size: 0}\n"},
+		{name: "Overflow", file: "mqtt: {overflow: drop-all}\n"},
+		{name: "SpoolMaxAge", file: "mqtt: {spool_dir: /tmp/spool, spool_max_age: 0}\n"},
+		{name: "EnvSpoolMaxSize", env: map[string]string{"INFLUX_SPOOL_MAX_SIZE": "-1"}},
+		{name: "EnvTimeout", env: map[string]string{"RESTAPI_TIMEOUT": "0"}},
+		{name: "EnvNotANumber", env: map[string]string{"POLLING_RATE": "often"}},
+		{name: "EnvFeature", env: map[string]string{"ENABLE_MQTT": "maybe"}},
+		{name: "EnvChannels", env: map[string]string{"CHANNELS": "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			path := ""
+			if tt.file != "" {
+				path = writeConfigFile(t, tt.file)
+			}
+
+			if _, err := LoadConfig(path); !errors.Is(err, ErrInvalidValue) {
+				t.Errorf("LoadConfig() error = %v, want %v", err, ErrInvalidValue)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_MissingFile(t *testing.T) {
+	_, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadConfig() error = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestLoadConfig_Example(t *testing.T) {
+	cfg, err := LoadConfig("../scripts/roomlogg.yaml")
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	want := &ChannelInfo{Name: "Garden", Outdoor: true}
+	if got := cfg.RoomLogg.Channels.Lookup("", 4); !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadConfig() channel 4 = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,192 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/h44z/dntroomloggpro-go/internal"
+	"github.com/sirupsen/logrus"
+)
+
+type ConnectionState uint8
+
+const (
+	StateDisconnected ConnectionState = iota
+	StateConnected
+)
+
+func (s ConnectionState) String() string {
+	if s == StateConnected {
+		return "connected"
+	}
+	return "disconnected"
+}
+
+// ConnectionEvent is emitted whenever the connection state of a station changes.
+type ConnectionEvent struct {
+	Station string
+	State   ConnectionState
+	Time    time.Time
+	Err     error // Reason for the disconnect, if known
+}
+
+// ConnectionHandler is called for each connection state change. Handlers run in their own goroutine, one event after
+// the other in the order of the state changes, so they may call any method of the RoomLogg (e.g. Close or a fetch).
+type ConnectionHandler func(event ConnectionEvent)
+
+// PresenceWatcher is implemented by transports that can detect if the device is plugged in or removed.
+// The returned channel reports the presence of the device, the first value is the initial presence.
+// It is closed once done is closed.
+type PresenceWatcher interface {
+	WatchPresence(interval time.Duration, done <-chan struct{}) <-chan bool
+}
+
+// pendingEvent is a state change with the handlers that were registered when it happened.
+type pendingEvent struct {
+	event    ConnectionEvent
+	handlers []ConnectionHandler
+}
+
+var errDeviceRemoved = fmt.Errorf("%w: device removed", ErrDisconnected)
+
+// AddConnectionHandler registers a handler that gets notified about connection state changes.
+func (r *RoomLogg) AddConnectionHandler(handler ConnectionHandler) {
+	r.stateMux.Lock()
+	defer r.stateMux.Unlock()
+
+	r.handlers = append(r.handlers, handler)
+}
+
+// State returns the current connection state.
+func (r *RoomLogg) State() ConnectionState {
+	r.stateMux.Lock()
+	defer r.stateMux.Unlock()
+
+	return r.state
+}
+
+func (r *RoomLogg) setState(state ConnectionState, err error) {
+	r.stateMux.Lock()
+	if r.state == state {
+		r.stateMux.Unlock()
+		return
+	}
+	r.state = state
+	handlers := make([]ConnectionHandler, len(r.handlers))
+	copy(handlers, r.handlers)
+	event := ConnectionEvent{Station: r.id, State: state, Time: time.Now(), Err: err}
+	r.events = append(r.events, pendingEvent{event: event, handlers: handlers})
+	if !r.dispatching {
+		r.dispatching = true
+		go r.dispatch()
+	}
+	r.stateMux.Unlock()
+
+	logrus.Infof("DNT RoomLogg PRO %q is now %s", r.id, state)
+}
+
+// dispatch runs the handlers for the queued events until no event is left. The handlers run outside of the monitor
+// and without any lock, so that a handler can not block the state change that triggered it.
+func (r *RoomLogg) dispatch() {
+	for {
+		r.stateMux.Lock()
+		if len(r.events) == 0 {
+			r.dispatching = false
+			r.stateMux.Unlock()
+			return
+		}
+		pending := r.events[0]
+		r.events = r.events[1:]
+		r.stateMux.Unlock()
+
+		for _, handler := range pending.handlers {
+			handler(pending.event)
+		}
+	}
+}
+
+// StartMonitor watches for the device being removed and plugged in again. If the device reappears, the connection
+// is reopened with an exponential backoff. The transport must implement the PresenceWatcher interface.
+func (r *RoomLogg) StartMonitor(interval time.Duration) error {
+	watcher, ok := r.transport.(PresenceWatcher)
+	if !ok {
+		return errors.New("transport does not support presence detection")
+	}
+
+	r.StopMonitor()
+
+	r.stateMux.Lock()
+	defer r.stateMux.Unlock()
+
+	done := make(chan struct{})
+	exit := make(chan struct{})
+	r.monitorDone = done
+	r.monitorExit = exit
+	go func() {
+		defer close(exit)
+		r.monitor(watcher.WatchPresence(interval, done), done)
+	}()
+
+	return nil
+}
+
+// StopMonitor stops watching for device changes. It waits until the monitor has returned, so that a pending
+// reconnect can not reopen the device after StopMonitor.
+func (r *RoomLogg) StopMonitor() {
+	r.stateMux.Lock()
+	exit := r.monitorExit
+	if r.monitorDone != nil {
+		close(r.monitorDone)
+		r.monitorDone = nil
+		r.monitorExit = nil
+	}
+	r.stateMux.Unlock()
+
+	// The monitor might wait for stateMux or the transport lock, so wait without holding any lock
+	if exit != nil {
+		<-exit
+	}
+}
+
+func (r *RoomLogg) monitor(events <-chan bool, done <-chan struct{}) {
+	backoff := internal.Backoff{Min: time.Second, Max: time.Minute}
+	present := true
+	var retry <-chan time.Time
+
+	for {
+		select {
+		case p, ok := <-events:
+			if !ok {
+				return // monitor stopped
+			}
+			present = p
+			switch {
+			case !present:
+				logrus.Warnf("DNT RoomLogg PRO %q was removed", r.id)
+				r.lock <- struct{}{}
+				r.transport.Close()
+				r.unlock()
+				r.setState(StateDisconnected, errDeviceRemoved)
+				retry = nil
+				backoff.Reset()
+			case r.State() != StateConnected:
+				retry = time.After(0)
+			}
+		case <-done:
+			return
+		case <-retry:
+			retry = nil
+			if !present {
+				continue
+			}
+			if err := r.Reconnect(); err != nil {
+				delay := backoff.Next()
+				logrus.Warnf("Failed to reopen DNT RoomLogg PRO %q, retrying in %v: %v", r.id, delay, err)
+				retry = time.After(delay)
+				continue
+			}
+			backoff.Reset()
+		}
+	}
+}
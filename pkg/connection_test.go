@@ -0,0 +1,160 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+)
+
+func waitForEvent(t *testing.T, events <-chan ConnectionEvent) ConnectionEvent {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no connection event received")
+	}
+	return ConnectionEvent{}
+}
+
+func TestRoomLogg_Monitor(t *testing.T) {
+	e := NewEmulator()
+	r := NewRoomLoggWithTransport(&RoomLoggConfig{PollingRate: 60}, e)
+	r.id = "test"
+
+	events := make(chan ConnectionEvent, 10)
+	r.AddConnectionHandler(func(event ConnectionEvent) {
+		events <- event
+	})
+
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+	if event := waitForEvent(t, events); event.State != StateConnected || event.Station != "test" {
+		t.Errorf("Open() event = %v, want connected", event)
+	}
+
+	if err := r.StartMonitor(5 * time.Millisecond); err != nil {
+		t.Fatalf("StartMonitor() error = %v", err)
+	}
+
+	e.SetPresent(false)
+	if event := waitForEvent(t, events); event.State != StateDisconnected || event.Err == nil {
+		t.Errorf("monitor event = %v, want disconnected", event)
+	}
+	if _, err := r.FetchCurrentData(); err == nil {
+		t.Errorf("FetchCurrentData() expected error while device is removed")
+	}
+
+	e.SetPresent(true)
+	if event := waitForEvent(t, events); event.State != StateConnected {
+		t.Errorf("monitor event = %v, want connected", event)
+	}
+	if _, err := r.FetchCurrentData(); err != nil {
+		t.Errorf("FetchCurrentData() error = %v", err)
+	}
+}
+
+func TestRoomLogg_MonitorUnsupported(t *testing.T) {
+	r, _ := newScriptedRoomLogg(t)
+	defer r.Close()
+
+	if err := r.StartMonitor(time.Second); err == nil {
+		t.Errorf("StartMonitor() expected error for transport without presence detection")
+	}
+}
+
+func TestRoomLogg_CloseDuringReconnect(t *testing.T) {
+	e := NewEmulator()
+	r := NewRoomLoggWithTransport(&RoomLoggConfig{PollingRate: 60}, e)
+
+	events := make(chan ConnectionEvent, 10)
+	r.AddConnectionHandler(func(event ConnectionEvent) {
+		events <- event
+	})
+
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	waitForEvent(t, events)
+	if err := r.StartMonitor(5 * time.Millisecond); err != nil {
+		t.Fatalf("StartMonitor() error = %v", err)
+	}
+
+	e.SetPresent(false)
+	waitForEvent(t, events)
+
+	// Block the transport, so that the monitor waits for the lock when it reconnects
+	r.lock <- struct{}{}
+	e.SetPresent(true)
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		r.Close()
+		close(closed)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	r.unlock()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close() did not return")
+	}
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if e.isOpen {
+		t.Errorf("Close() left the device open after a pending reconnect")
+	}
+}
+
+func TestRoomLogg_HandlerCallsClose(t *testing.T) {
+	e := NewEmulator()
+	r := NewRoomLoggWithTransport(&RoomLoggConfig{PollingRate: 60}, e)
+
+	closed := make(chan struct{})
+	r.AddConnectionHandler(func(event ConnectionEvent) {
+		if event.State != StateDisconnected {
+			return
+		}
+		r.Close() // waits for the monitor that emitted the event
+		close(closed)
+	})
+
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if err := r.StartMonitor(5 * time.Millisecond); err != nil {
+		t.Fatalf("StartMonitor() error = %v", err)
+	}
+
+	e.SetPresent(false)
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close() from the connection handler did not return")
+	}
+}
+
+func TestRoomLogg_HandlerOrder(t *testing.T) {
+	r, _ := newScriptedRoomLogg(t)
+	defer r.Close()
+
+	events := make(chan ConnectionEvent, 10)
+	r.AddConnectionHandler(func(event ConnectionEvent) {
+		if event.State == StateDisconnected {
+			_ = r.Reconnect() // emits the next event while this one is handled
+		}
+		events <- event
+	})
+
+	r.setState(StateDisconnected, ErrDisconnected)
+	for _, want := range []ConnectionState{StateDisconnected, StateConnected} {
+		if event := waitForEvent(t, events); event.State != want {
+			t.Errorf("event = %v, want %v", event.State, want)
+		}
+	}
+}
@@ -2,7 +2,13 @@ package pkg
 
 import (
 	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -20,8 +26,8 @@ const (
 
 	DSTOn    Flag = 0x01
 	DSTOff   Flag = 0x00
-	AlarmOn  Flag = 0x01
-	AlarmOff Flag = 0x00
+	AlarmOn  Flag = 0x00 // the station uses inverted alarm flags, see TestNewAlarmSettingsData
+	AlarmOff Flag = 0x01
 
 	TimeFormatEurope        TimeFormat = 0x00
 	TimeFormatEnglishPrefix TimeFormat = 0x01
@@ -53,6 +59,10 @@ const (
 	CommandGetCurrentData      = 0x03
 	CommandGetSettings         = 0x04
 	CommandGetInterval         = 0x41
+	// CommandGetTime is not part of the known command set, it is derived from the SetInterval/GetInterval pair.
+	// It is only sent if RoomLoggConfig.GetTime is enabled. Use FetchClockDrift with a protocol trace to verify it on
+	// a real base station.
+	CommandGetTime = 0x31
 
 	CommandSetAlarmSettings    = 0x12
 	CommandSetTemperatureAlarm = 0x14
@@ -67,31 +77,141 @@ const (
 	CommandEndStore   = 0x2f
 )
 
+var commandNames = map[byte]string{
+	CommandGetSDCardFile:       "GetSDCardFile",
+	CommandGetExportFile:       "GetExportFile",
+	CommandGetAlarmSettings:    "GetAlarmSettings",
+	CommandGetTemperatureAlarm: "GetTemperatureAlarm",
+	CommandGetHumidityAlarm:    "GetHumidityAlarm",
+	CommandGetCalibration:      "GetCalibration",
+	CommandGetCurrentData:      "GetCurrentData",
+	CommandGetSettings:         "GetSettings",
+	CommandGetInterval:         "GetInterval",
+	CommandGetTime:             "GetTime",
+	CommandSetAlarmSettings:    "SetAlarmSettings",
+	CommandSetTemperatureAlarm: "SetTemperatureAlarm",
+	CommandSetHumidityAlarm:    "SetHumidityAlarm",
+	CommandSetCalibration:      "SetCalibration",
+	CommandSetSettings:         "SetSettings",
+	CommandSetInterval:         "SetInterval",
+	CommandSetLanguage:         "SetLanguage",
+	CommandSetTime:             "SetTime",
+	CommandStartStore:          "StartStore",
+	CommandEndStore:            "EndStore",
+}
+
+// CommandName returns the name of the command, e.g. GetCurrentData for CommandGetCurrentData.
+func CommandName(command byte) string {
+	if name, ok := commandNames[command]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(0x%02x)", command)
+}
+
+// Symbol returns the symbol of the temperature unit, e.g. °C.
+func (u Unit) Symbol() string {
+	if u == UnitFahrenheit {
+		return "°F"
+	}
+	return "°C"
+}
+
+// ParseUnit parses a temperature unit name like celsius, fahrenheit, C or °F (case insensitive).
+func ParseUnit(name string) (Unit, error) {
+	switch strings.ToLower(strings.TrimPrefix(name, "°")) {
+	case "c", "celsius":
+		return UnitCelsius, nil
+	case "f", "fahrenheit":
+		return UnitFahrenheit, nil
+	}
+	return 0, fmt.Errorf("%w: unknown temperature unit %q", ErrInvalidValue, name)
+}
+
+// ConvertTemperature converts a temperature between the units.
+func ConvertTemperature(temperature float64, from, to Unit) float64 {
+	switch {
+	case from == to:
+		return temperature
+	case to == UnitFahrenheit:
+		return temperature*9/5 + 32
+	default:
+		return (temperature - 32) * 5 / 9
+	}
+}
+
 type Data interface {
 	RawBytes() []byte
 }
 
+// Payload sizes of the fixed size data types.
+const (
+	settingsSize      = 7 + 5*4 // settings + 5 areas
+	alarmSettingsSize = 2 + 4   // enable flags + 4 channel bitmasks
+	timeSize          = 7 + 1   // date and time + timezone
+)
+
+// responsePayloadSizes are the payload sizes of the responses to the get commands. The station does not echo the
+// command byte, so the payload size is the only way to tell which command a response belongs to. File pages have
+// a variable size and are not listed.
+var responsePayloadSizes = map[byte]int{
+	CommandGetCurrentData:      8 * 3,
+	CommandGetCalibration:      8 * 3,
+	CommandGetInterval:         1,
+	CommandGetSettings:         settingsSize,
+	CommandGetAlarmSettings:    alarmSettingsSize,
+	CommandGetTemperatureAlarm: 8 * 4,
+	CommandGetHumidityAlarm:    8 * 2,
+	CommandGetTime:             timeSize,
+	CommandStartStore:          1,
+}
+
+// checkSize returns an error if raw is shorter than size bytes.
+func checkSize(name string, raw []byte, size int) error {
+	if len(raw) < size {
+		return fmt.Errorf("%w: %s needs %d bytes, got %d", ErrBadFrame, name, size, len(raw))
+	}
+	return nil
+}
+
+// checkMultiple returns an error if the length of raw is not a multiple of size bytes.
+func checkMultiple(name string, raw []byte, size int) error {
+	if len(raw)%size != 0 {
+		return fmt.Errorf("%w: %s need a multiple of %d bytes, got %d", ErrBadFrame, name, size, len(raw))
+	}
+	return nil
+}
+
 type ChannelData struct {
 	Number      int
 	Temperature float64
 	Humidity    float64
+	Unit        Unit         // temperature unit, the raw channel data does not contain it
+	Info        *ChannelInfo // metadata from the channel registry, nil if the channel is not configured
 }
 
-func NewChannelData(raw []byte) *ChannelData {
+func NewChannelData(raw []byte) (*ChannelData, error) {
+	if err := checkSize("channel data", raw, 3); err != nil {
+		return nil, err
+	}
+
 	d := &ChannelData{}
 	tmp := int16(binary.BigEndian.Uint16([]byte{raw[0], raw[1]}))
 	d.Temperature = float64(tmp) / 10
 	d.Humidity = float64(raw[2])
 
-	return d
+	return d, nil
 }
 
-func NewChannelsData(raw []byte) []*ChannelData {
+func NewChannelsData(raw []byte) ([]*ChannelData, error) {
+	if err := checkMultiple("channel data", raw, 3); err != nil {
+		return nil, err
+	}
+
 	numChannels := len(raw) / 3 // 1 channel has 3 bytes
 	channels := make([]*ChannelData, 0, numChannels)
 	channelIndex := 1
 	for i := 0; i < 3*numChannels; i += 3 {
-		channel := NewChannelData(raw[i : i+3])
+		channel, _ := NewChannelData(raw[i : i+3])
 		channel.Number = channelIndex
 		if channel.Humidity != 255 {
 			channels = append(channels, channel)
@@ -99,15 +219,35 @@ func NewChannelsData(raw []byte) []*ChannelData {
 		channelIndex++
 	}
 
-	return channels
+	return channels, nil
+}
+
+// ConvertTo returns a copy of the channel data in the given temperature unit. The temperature is rounded to the
+// resolution of the station (0.1).
+func (d *ChannelData) ConvertTo(unit Unit) *ChannelData {
+	c := *d
+	c.Temperature = roundTenth(ConvertTemperature(d.Temperature, d.Unit, unit))
+	c.Unit = unit
+
+	return &c
+}
+
+// ConvertChannels converts all channels to the given temperature unit.
+func ConvertChannels(channels []*ChannelData, unit Unit) []*ChannelData {
+	converted := make([]*ChannelData, len(channels))
+	for i, channel := range channels {
+		converted[i] = channel.ConvertTo(unit)
+	}
+
+	return converted
 }
 
 func (d *ChannelData) RawBytes() []byte {
 	r := make([]byte, 3)
 
-	r[2] = byte(d.Humidity)
+	r[2] = byte(math.Round(d.Humidity))
 	tmp := make([]byte, 2)
-	binary.BigEndian.PutUint16(tmp, uint16(d.Temperature*10))
+	binary.BigEndian.PutUint16(tmp, encodeTemperature(d.Temperature))
 	r[0] = tmp[0]
 	r[1] = tmp[1]
 
@@ -116,8 +256,12 @@ func (d *ChannelData) RawBytes() []byte {
 
 type IntervalData uint8 // Minutes
 
-func NewIntervalData(raw []byte) IntervalData {
-	return IntervalData(raw[0])
+func NewIntervalData(raw []byte) (IntervalData, error) {
+	if err := checkSize("interval", raw, 1); err != nil {
+		return 0, err
+	}
+
+	return IntervalData(raw[0]), nil
 }
 
 func (d IntervalData) RawBytes() []byte {
@@ -130,8 +274,12 @@ func (d IntervalData) RawBytes() []byte {
 
 type LanguageData Language // Either DE or EN
 
-func NewLanguageData(raw []byte) LanguageData {
-	return LanguageData(raw[0])
+func NewLanguageData(raw []byte) (LanguageData, error) {
+	if err := checkSize("language", raw, 1); err != nil {
+		return 0, err
+	}
+
+	return LanguageData(raw[0]), nil
 }
 
 func (d LanguageData) RawBytes() []byte {
@@ -152,7 +300,11 @@ type SettingsAreaData struct {
 	HeatIndex   map[uint8]bool
 }
 
-func NewSettingsAreaData(raw []byte) *SettingsAreaData {
+func NewSettingsAreaData(raw []byte) (*SettingsAreaData, error) {
+	if err := checkSize("settings area", raw, 4); err != nil {
+		return nil, err
+	}
+
 	d := &SettingsAreaData{}
 	d.Temperature = make(map[uint8]bool, 5)
 	d.DewPoint = make(map[uint8]bool, 5)
@@ -181,7 +333,7 @@ func NewSettingsAreaData(raw []byte) *SettingsAreaData {
 		}
 	}
 
-	return d
+	return d, nil
 }
 
 func (d *SettingsAreaData) RawBytes() []byte {
@@ -218,6 +370,11 @@ func (d *SettingsAreaData) RawBytes() []byte {
 	return r
 }
 
+// encodeTemperature converts a temperature to the signed 1/10 degree representation of the station.
+func encodeTemperature(t float64) uint16 {
+	return uint16(int16(math.Round(t * 10)))
+}
+
 func setBit32(n uint32, pos uint) uint32 {
 	n |= 1 << pos
 	return n
@@ -239,7 +396,11 @@ type SettingsData struct {
 	Areas         [5]*SettingsAreaData
 }
 
-func NewSettingsData(raw []byte) *SettingsData {
+func NewSettingsData(raw []byte) (*SettingsData, error) {
+	if err := checkSize("settings", raw, settingsSize); err != nil {
+		return nil, err
+	}
+
 	d := &SettingsData{}
 	d.GraphType = GraphType(raw[0])
 	d.GraphInterval = GraphInterval(raw[1])
@@ -252,17 +413,17 @@ func NewSettingsData(raw []byte) *SettingsData {
 	areaIndex := 0 // index is 0 based, area number is index+1
 	iOffset := 7
 	for i := 0; i < 4*5; i += 4 { // One area consists of 4 bytes, 5 areas in total
-		area := NewSettingsAreaData(raw[i+iOffset : i+iOffset+4])
+		area, _ := NewSettingsAreaData(raw[i+iOffset : i+iOffset+4])
 		area.Area = areaIndex + 1
 		d.Areas[areaIndex] = area
 		areaIndex++
 	}
 
-	return d
+	return d, nil
 }
 
 func (d *SettingsData) RawBytes() []byte {
-	r := make([]byte, 27)
+	r := make([]byte, settingsSize)
 	r[0] = byte(d.GraphType)
 	r[1] = byte(d.GraphInterval)
 	r[2] = byte(d.TimeFormat)
@@ -294,7 +455,11 @@ type AlarmSettingsData struct {
 	HumidityHighAlarm    map[uint8]bool
 }
 
-func NewAlarmSettingsData(raw []byte) *AlarmSettingsData {
+func NewAlarmSettingsData(raw []byte) (*AlarmSettingsData, error) {
+	if err := checkSize("alarm settings", raw, alarmSettingsSize); err != nil {
+		return nil, err
+	}
+
 	d := &AlarmSettingsData{}
 	d.EnableTemperatureAlarm = Flag(raw[0]) // 0x01 = off, 0x00 = on
 	d.EnableHumidityAlarm = Flag(raw[1])
@@ -318,7 +483,7 @@ func NewAlarmSettingsData(raw []byte) *AlarmSettingsData {
 			d.TemperatureLowAlarm = flags
 		}
 	}
-	return d
+	return d, nil
 }
 
 func setBit8(n uint8, pos uint) uint8 {
@@ -364,33 +529,41 @@ type HumidityAlarmData struct {
 	High    float64
 }
 
-func NewHumidityAlarmData(raw []byte) *HumidityAlarmData {
+func NewHumidityAlarmData(raw []byte) (*HumidityAlarmData, error) {
+	if err := checkSize("humidity alarm", raw, 2); err != nil {
+		return nil, err
+	}
+
 	d := &HumidityAlarmData{}
 	d.High = float64(raw[0])
 	d.Low = float64(raw[1])
 
-	return d
+	return d, nil
 }
 
-func NewHumidityAlarmsData(raw []byte) []*HumidityAlarmData {
+func NewHumidityAlarmsData(raw []byte) ([]*HumidityAlarmData, error) {
+	if err := checkMultiple("humidity alarms", raw, 2); err != nil {
+		return nil, err
+	}
+
 	numChannels := len(raw) / 2 // 1 channel has 2 bytes
 
 	channels := make([]*HumidityAlarmData, 0, numChannels)
 	channelIndex := 1
 	for i := 0; i < 2*numChannels; i += 2 {
-		channel := NewHumidityAlarmData(raw[i : i+2])
+		channel, _ := NewHumidityAlarmData(raw[i : i+2])
 		channel.Channel = channelIndex
 		channels = append(channels, channel)
 		channelIndex++
 	}
 
-	return channels
+	return channels, nil
 }
 
 func (d *HumidityAlarmData) RawBytes() []byte {
 	r := make([]byte, 2)
-	r[0] = byte(d.High)
-	r[1] = byte(d.Low)
+	r[0] = byte(math.Round(d.High))
+	r[1] = byte(math.Round(d.Low))
 
 	return r
 }
@@ -401,38 +574,46 @@ type TemperatureAlarmData struct {
 	High    float64
 }
 
-func NewTemperatureAlarmData(raw []byte) *TemperatureAlarmData {
+func NewTemperatureAlarmData(raw []byte) (*TemperatureAlarmData, error) {
+	if err := checkSize("temperature alarm", raw, 4); err != nil {
+		return nil, err
+	}
+
 	d := &TemperatureAlarmData{}
 	tmp := int16(binary.BigEndian.Uint16([]byte{raw[0], raw[1]}))
 	d.High = float64(tmp) / 10
 	tmp = int16(binary.BigEndian.Uint16([]byte{raw[2], raw[3]}))
 	d.Low = float64(tmp) / 10
 
-	return d
+	return d, nil
 }
 
-func NewTemperatureAlarmsData(raw []byte) []*TemperatureAlarmData {
+func NewTemperatureAlarmsData(raw []byte) ([]*TemperatureAlarmData, error) {
+	if err := checkMultiple("temperature alarms", raw, 4); err != nil {
+		return nil, err
+	}
+
 	numChannels := len(raw) / 4 // 1 channel has 4 bytes
 
 	channels := make([]*TemperatureAlarmData, 0, numChannels)
 	channelIndex := 1
 	for i := 0; i < 4*numChannels; i += 4 {
-		channel := NewTemperatureAlarmData(raw[i : i+4])
+		channel, _ := NewTemperatureAlarmData(raw[i : i+4])
 		channel.Channel = channelIndex
 		channels = append(channels, channel)
 		channelIndex++
 	}
 
-	return channels
+	return channels, nil
 }
 
 func (d *TemperatureAlarmData) RawBytes() []byte {
 	r := make([]byte, 4)
 	tmp := make([]byte, 2)
-	binary.BigEndian.PutUint16(tmp, uint16(d.High*10))
+	binary.BigEndian.PutUint16(tmp, encodeTemperature(d.High))
 	r[0] = tmp[0]
 	r[1] = tmp[1]
-	binary.BigEndian.PutUint16(tmp, uint16(d.Low*10))
+	binary.BigEndian.PutUint16(tmp, encodeTemperature(d.Low))
 	r[2] = tmp[0]
 	r[3] = tmp[1]
 
@@ -445,50 +626,74 @@ type CalibrationData struct {
 	Humidity    float64
 }
 
-func NewCalibrationData(raw []byte) *CalibrationData {
+func NewCalibrationData(raw []byte) (*CalibrationData, error) {
+	if err := checkSize("calibration", raw, 3); err != nil {
+		return nil, err
+	}
+
 	d := &CalibrationData{}
 	tmp := int16(binary.BigEndian.Uint16([]byte{raw[0], raw[1]}))
 	d.Temperature = float64(tmp) / 10
 	d.Humidity = float64(raw[2])
 
-	return d
+	return d, nil
 }
 
-func NewCalibrationsData(raw []byte) []*CalibrationData {
+func NewCalibrationsData(raw []byte) ([]*CalibrationData, error) {
+	if err := checkMultiple("calibration", raw, 3); err != nil {
+		return nil, err
+	}
+
 	numChannels := len(raw) / 3 // 1 channel has 3 bytes
 	channels := make([]*CalibrationData, 0, numChannels)
 	channelIndex := 1
 	for i := 0; i < 3*numChannels; i += 3 {
-		channel := NewCalibrationData(raw[i : i+3])
+		channel, _ := NewCalibrationData(raw[i : i+3])
 		channel.Channel = channelIndex
 		channels = append(channels, channel)
 		channelIndex++
 	}
 
-	return channels
+	return channels, nil
 }
 
 func (d *CalibrationData) RawBytes() []byte {
 	r := make([]byte, 3)
 
-	r[2] = byte(d.Humidity)
+	r[2] = byte(math.Round(d.Humidity))
 	tmp := make([]byte, 2)
-	binary.BigEndian.PutUint16(tmp, uint16(d.Temperature*10))
+	binary.BigEndian.PutUint16(tmp, encodeTemperature(d.Temperature))
 	r[0] = tmp[0]
 	r[1] = tmp[1]
 
 	return r
 }
 
+// TimeData is the station clock. The last byte of the frame holds the standard UTC offset in hours, like
+// SettingsData.TimeZone. The DST hour is not part of it, it is set with SettingsData.DST.
 type TimeData struct {
 	time time.Time
+	dst  bool // the clock runs in daylight saving time, time is one hour ahead of the standard offset
 }
 
-func NewTimeData(raw []byte) TimeData {
-	d := TimeData{}
+// NewTimeData decodes the time frame of a station that does not run in daylight saving time.
+func NewTimeData(raw []byte) (TimeData, error) {
+	return newTimeData(raw, false)
+}
+
+// newTimeData decodes the time frame, dst is the DST flag of the station settings.
+func newTimeData(raw []byte, dst bool) (TimeData, error) {
+	if err := checkSize("time", raw, timeSize); err != nil {
+		return TimeData{}, err
+	}
+
+	d := TimeData{dst: dst}
 	year := int16(binary.BigEndian.Uint16([]byte{raw[0], raw[1]}))
 
-	offset := int8(raw[7]) // TODO: is this byte really the timezone??
+	offset := int8(raw[7])
+	if dst {
+		offset++
+	}
 	sign := "+"
 	if offset < 0 {
 		sign = "" // neg sign will be added automatically
@@ -497,11 +702,22 @@ func NewTimeData(raw []byte) TimeData {
 	d.time = time.Date(int(year), time.Month(raw[2]), int(raw[3]), int(raw[4]), int(raw[5]), int(raw[6]), 0, time.UTC)
 	d.time = d.time.Add(time.Duration(-offset) * time.Hour)
 	d.time = d.time.In(location)
-	return d
+	return d, nil
+}
+
+// NewTimeDataFromTime creates the time data for the given time. The station gets the date and time in the
+// timezone of t.
+func NewTimeDataFromTime(t time.Time) TimeData {
+	return TimeData{time: t, dst: t.IsDST()}
+}
+
+// Time returns the time in the timezone of the station.
+func (d *TimeData) Time() time.Time {
+	return d.time
 }
 
 func (d *TimeData) RawBytes() []byte {
-	r := make([]byte, 8)
+	r := make([]byte, timeSize)
 
 	tmp := make([]byte, 2)
 	binary.BigEndian.PutUint16(tmp, uint16(d.time.Year()))
@@ -512,8 +728,275 @@ func (d *TimeData) RawBytes() []byte {
 	r[4] = byte(d.time.Hour())
 	r[5] = byte(d.time.Minute())
 	r[6] = byte(d.time.Second())
-	_, offset := d.time.Zone()
-	r[7] = byte(int8(offset / 60 / 60)) // TODO: is this byte really the timezone??
+	dst, zone := zoneSettings(d.time)
+	if d.dst && dst == DSTOff {
+		zone-- // decoded frames keep the DST hour in a fixed zone
+	}
+	r[7] = byte(zone)
+
+	return r
+}
+
+// ClockDrift compares the clock of the base station with the host clock. The station clock has a resolution of one
+// second, so a drift below one second is not significant.
+type ClockDrift struct {
+	StationTime    time.Time
+	HostTime       time.Time
+	Drift          time.Duration // station clock minus host clock, positive if the station clock is ahead
+	WallClockDrift time.Duration // like Drift, but compares the displayed date and time and ignores the timezones
+	StationOffset  int           // UTC offset of the station in seconds, decoded from the timezone byte
+	HostOffset     int           // UTC offset of the host in seconds
+}
+
+// NewClockDrift compares the station time with the host time. If the timezone byte is decoded correctly,
+// StationOffset matches HostOffset after a time sync and Drift equals WallClockDrift.
+func NewClockDrift(station TimeData, host time.Time) *ClockDrift {
+	d := &ClockDrift{StationTime: station.time, HostTime: host}
+	d.Drift = station.time.Sub(host)
+	d.WallClockDrift = wallClock(station.time).Sub(wallClock(host))
+	_, d.StationOffset = station.time.Zone()
+	_, d.HostOffset = host.Zone()
+
+	return d
+}
+
+// wallClock returns the displayed date and time of t, as if it was UTC.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+const historyRecordSize = 7 + 3*8 // timestamp + 8 channels
+
+// HistoryRecord is one logged entry of the base station memory. A record consists of a 7 byte timestamp in station
+// time (year, month, day, hour, minute, second) followed by the channel data of all 8 channels.
+//
+// Experimental: the record layout is not documented by the vendor and is only verified against the Emulator.
+type HistoryRecord struct {
+	Time     time.Time
+	Channels []*ChannelData // Offline channels are omitted
+}
+
+func NewHistoryRecord(raw []byte) (*HistoryRecord, error) {
+	if err := checkSize("history record", raw, historyRecordSize); err != nil {
+		return nil, err
+	}
+
+	d := &HistoryRecord{}
+	d.Time = decodeRecordTime(raw)
+	d.Channels, _ = NewChannelsData(raw[7:historyRecordSize])
+
+	return d, nil
+}
+
+func NewHistoryRecords(raw []byte) ([]*HistoryRecord, error) {
+	if err := checkMultiple("history records", raw, historyRecordSize); err != nil {
+		return nil, err
+	}
+
+	numRecords := len(raw) / historyRecordSize
+	records := make([]*HistoryRecord, 0, numRecords)
+	for i := 0; i < historyRecordSize*numRecords; i += historyRecordSize {
+		record, _ := NewHistoryRecord(raw[i : i+historyRecordSize])
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func (d *HistoryRecord) RawBytes() []byte {
+	r := make([]byte, historyRecordSize)
+	encodeRecordTime(r, d.Time)
+
+	// Mark all channels as offline, then fill in the available ones
+	for i := 7; i < historyRecordSize; i += 3 {
+		r[i] = 0x7f
+		r[i+1] = 0xff
+		r[i+2] = 0xff
+	}
+	for _, channel := range d.Channels {
+		if channel.Number < 1 || channel.Number > 8 {
+			continue
+		}
+		offset := 7 + (channel.Number-1)*3
+		copy(r[offset:offset+3], channel.RawBytes())
+	}
+
+	return r
+}
+
+// decodeRecordTime parses the 7 byte timestamp of a logged record (year, month, day, hour, minute, second).
+// Records do not contain a timezone, they are decoded in host local time. RoomLogg moves them to the timezone of
+// the station clock with inLocation.
+func decodeRecordTime(raw []byte) time.Time {
+	year := int(binary.BigEndian.Uint16([]byte{raw[0], raw[1]}))
+	return time.Date(year, time.Month(raw[2]), int(raw[3]), int(raw[4]), int(raw[5]), int(raw[6]), 0, time.Local)
+}
+
+// inLocation returns the time with the same date and time of day as t, but in loc.
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+// encodeRecordTime writes the 7 byte timestamp of a logged record to r.
+func encodeRecordTime(r []byte, t time.Time) {
+	binary.BigEndian.PutUint16(r[0:2], uint16(t.Year()))
+	r[2] = byte(t.Month())
+	r[3] = byte(t.Day())
+	r[4] = byte(t.Hour())
+	r[5] = byte(t.Minute())
+	r[6] = byte(t.Second())
+}
+
+const exportRecordSize = 7 + 1 + 3 // timestamp + channel number + channel data
+
+// ExportRecord is one entry of the station export file. A record consists of a 7 byte timestamp in station time,
+// the channel number and the channel data (temperature and humidity).
+//
+// Experimental: the record layout is not documented by the vendor and is only verified against the Emulator.
+type ExportRecord struct {
+	Time        time.Time
+	Channel     int
+	Temperature float64
+	Humidity    float64
+	Unit        Unit // temperature unit, the raw record does not contain it
+}
+
+func NewExportRecord(raw []byte) (*ExportRecord, error) {
+	if err := checkSize("export record", raw, exportRecordSize); err != nil {
+		return nil, err
+	}
+
+	d := &ExportRecord{}
+	d.Time = decodeRecordTime(raw)
+	d.Channel = int(raw[7])
+	channel, _ := NewChannelData(raw[8:exportRecordSize])
+	d.Temperature = channel.Temperature
+	d.Humidity = channel.Humidity
+
+	return d, nil
+}
+
+func (d *ExportRecord) RawBytes() []byte {
+	r := make([]byte, exportRecordSize)
+	encodeRecordTime(r, d.Time)
+	r[7] = byte(d.Channel)
+	channel := &ChannelData{Temperature: d.Temperature, Humidity: d.Humidity}
+	copy(r[8:], channel.RawBytes())
+
+	return r
+}
+
+// ConvertTo returns a copy of the record in the given temperature unit, like ChannelData.ConvertTo.
+func (d *ExportRecord) ConvertTo(unit Unit) *ExportRecord {
+	c := *d
+	c.Temperature = roundTenth(ConvertTemperature(d.Temperature, d.Unit, unit))
+	c.Unit = unit
+
+	return &c
+}
+
+// ExportData is the decoded station export file.
+type ExportData []*ExportRecord
+
+func NewExportData(raw []byte) (ExportData, error) {
+	if err := checkMultiple("export records", raw, exportRecordSize); err != nil {
+		return nil, err
+	}
+
+	numRecords := len(raw) / exportRecordSize
+	records := make(ExportData, 0, numRecords)
+	for i := 0; i < exportRecordSize*numRecords; i += exportRecordSize {
+		record, _ := NewExportRecord(raw[i : i+exportRecordSize])
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func (d ExportData) RawBytes() []byte {
+	r := make([]byte, 0, exportRecordSize*len(d))
+	for _, record := range d {
+		r = append(r, record.RawBytes()...)
+	}
+
+	return r
+}
+
+// WriteCSV writes all records as CSV, including a header line. Timestamps are formatted as RFC3339, the unit as symbol.
+func (d ExportData) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"time", "channel", "temperature", "humidity", "unit"}); err != nil {
+		return err
+	}
+	for _, record := range d {
+		err := cw.Write([]string{
+			record.Time.Format(time.RFC3339),
+			strconv.Itoa(record.Channel),
+			strconv.FormatFloat(record.Temperature, 'f', 1, 64),
+			strconv.FormatFloat(record.Humidity, 'f', 0, 64),
+			record.Unit.Symbol(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// WriteJSON writes all records as JSON array.
+func (d ExportData) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(d)
+}
+
+// maxFilePages bounds the number of pages of a file transfer, the page count is reported by the station.
+const maxFilePages = 1024
+
+// FilePageData is one page of a file transfer (SD card or export file). The first two bytes hold the total number
+// of pages, the remaining bytes are the records of the page.
+//
+// Experimental: the page layout is not documented by the vendor and is only verified against the Emulator.
+type FilePageData struct {
+	Pages   int
+	Records []byte
+}
+
+func NewFilePageData(raw []byte) (*FilePageData, error) {
+	if err := checkSize("file page", raw, 2); err != nil {
+		return nil, err
+	}
+
+	d := &FilePageData{}
+	d.Pages = int(binary.BigEndian.Uint16([]byte{raw[0], raw[1]}))
+	d.Records = raw[2:]
+
+	return d, nil
+}
+
+func (d *FilePageData) RawBytes() []byte {
+	r := make([]byte, 2, 2+len(d.Records))
+	binary.BigEndian.PutUint16(r, uint16(d.Pages))
+	r = append(r, d.Records...)
+
+	return r
+}
+
+// FilePageRequestData is the payload of a file page request. Experimental, like FilePageData.
+type FilePageRequestData uint16 // Page index, 0 based
+
+func NewFilePageRequestData(raw []byte) (FilePageRequestData, error) {
+	if err := checkSize("file page request", raw, 2); err != nil {
+		return 0, err
+	}
+
+	return FilePageRequestData(binary.BigEndian.Uint16([]byte{raw[0], raw[1]})), nil
+}
+
+func (d FilePageRequestData) RawBytes() []byte {
+	r := make([]byte, 2)
+
+	binary.BigEndian.PutUint16(r, uint16(d))
 
 	return r
 }
@@ -1,6 +1,8 @@
 package pkg
 
 import (
+	"bytes"
+	"errors"
 	"reflect"
 	"testing"
 	"time"
@@ -51,7 +53,11 @@ func TestNewChannelData(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewChannelData(tt.args.raw); !reflect.DeepEqual(got, tt.want) {
+			got, err := NewChannelData(tt.args.raw)
+			if err != nil {
+				t.Fatalf("NewChannelData() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewChannelData() = %v, want %v", got, tt.want)
 			}
 		})
@@ -116,7 +122,11 @@ func TestNewChannelsData(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewChannelsData(tt.args.raw); !reflect.DeepEqual(got, tt.want) {
+			got, err := NewChannelsData(tt.args.raw)
+			if err != nil {
+				t.Fatalf("NewChannelsData() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewChannelsData() = %v, want %v", got, tt.want)
 			}
 		})
@@ -216,7 +226,11 @@ func TestNewCalibrationData(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewCalibrationData(tt.args.raw); !reflect.DeepEqual(got, tt.want) {
+			got, err := NewCalibrationData(tt.args.raw)
+			if err != nil {
+				t.Fatalf("NewCalibrationData() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewCalibrationData() = %v, want %v", got, tt.want)
 			}
 		})
@@ -281,7 +295,11 @@ func TestNewCalibrationsData(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewCalibrationsData(tt.args.raw); !reflect.DeepEqual(got, tt.want) {
+			got, err := NewCalibrationsData(tt.args.raw)
+			if err != nil {
+				t.Fatalf("NewCalibrationsData() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewCalibrationsData() = %v, want %v", got, tt.want)
 			}
 		})
@@ -374,7 +392,11 @@ func TestNewIntervalData(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewIntervalData(tt.args.raw); got != tt.want {
+			got, err := NewIntervalData(tt.args.raw)
+			if err != nil {
+				t.Fatalf("NewIntervalData() error = %v", err)
+			}
+			if got != tt.want {
 				t.Errorf("NewIntervalData() = %v, want %v", got, tt.want)
 			}
 		})
@@ -586,7 +608,11 @@ func TestNewSettingsAreaData(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewSettingsAreaData(tt.args.raw); !reflect.DeepEqual(got, tt.want) {
+			got, err := NewSettingsAreaData(tt.args.raw)
+			if err != nil {
+				t.Fatalf("NewSettingsAreaData() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewSettingsAreaData() = %v, want %v", got, tt.want)
 			}
 		})
@@ -948,7 +974,11 @@ func TestNewSettingsData(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewSettingsData(tt.args.raw); !reflect.DeepEqual(got, tt.want) {
+			got, err := NewSettingsData(tt.args.raw)
+			if err != nil {
+				t.Fatalf("NewSettingsData() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewSettingsData() = %v, want %v", got, tt.want)
 			}
 		})
@@ -1314,7 +1344,11 @@ func TestNewAlarmSettingsData(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewAlarmSettingsData(tt.args.raw); !reflect.DeepEqual(got, tt.want) {
+			got, err := NewAlarmSettingsData(tt.args.raw)
+			if err != nil {
+				t.Fatalf("NewAlarmSettingsData() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewAlarmSettingsData() = %v, want %v", got, tt.want)
 			}
 		})
@@ -1514,7 +1548,11 @@ func TestNewHumidityAlarmData(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewHumidityAlarmData(tt.args.raw); !reflect.DeepEqual(got, tt.want) {
+			got, err := NewHumidityAlarmData(tt.args.raw)
+			if err != nil {
+				t.Fatalf("NewHumidityAlarmData() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewHumidityAlarmData() = %v, want %v", got, tt.want)
 			}
 		})
@@ -1581,7 +1619,11 @@ func TestNewHumidityAlarmsData(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewHumidityAlarmsData(tt.args.raw); !reflect.DeepEqual(got, tt.want) {
+			got, err := NewHumidityAlarmsData(tt.args.raw)
+			if err != nil {
+				t.Fatalf("NewHumidityAlarmsData() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewHumidityAlarmsData() = %v, want %v", got, tt.want)
 			}
 		})
@@ -1673,7 +1715,11 @@ func TestNewTemperatureAlarmData(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewTemperatureAlarmData(tt.args.raw); !reflect.DeepEqual(got, tt.want) {
+			got, err := NewTemperatureAlarmData(tt.args.raw)
+			if err != nil {
+				t.Fatalf("NewTemperatureAlarmData() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewTemperatureAlarmData() = %v, want %v", got, tt.want)
 			}
 		})
@@ -1740,7 +1786,11 @@ func TestNewTemperatureAlarmsData(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewTemperatureAlarmsData(tt.args.raw); !reflect.DeepEqual(got, tt.want) {
+			got, err := NewTemperatureAlarmsData(tt.args.raw)
+			if err != nil {
+				t.Fatalf("NewTemperatureAlarmsData() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewTemperatureAlarmsData() = %v, want %v", got, tt.want)
 			}
 		})
@@ -1822,7 +1872,11 @@ func TestNewLanguageData(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewLanguageData(tt.args.raw); got != tt.want {
+			got, err := NewLanguageData(tt.args.raw)
+			if err != nil {
+				t.Fatalf("NewLanguageData() error = %v", err)
+			}
+			if got != tt.want {
 				t.Errorf("NewLanguageData() = %v, want %v", got, tt.want)
 			}
 		})
@@ -1906,13 +1960,55 @@ func TestNewTimeData(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewTimeData(tt.args.raw); !reflect.DeepEqual(got, tt.want) {
+			got, err := NewTimeData(tt.args.raw)
+			if err != nil {
+				t.Fatalf("NewTimeData() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewTimeData() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
+func TestNewClockDrift(t *testing.T) {
+	host := time.Date(2022, 8, 1, 10, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+	tests := []struct {
+		name    string
+		station []byte
+		want    *ClockDrift
+	}{
+		{
+			name:    "Synced",
+			station: []byte{0x07, 0xe6, 0x08, 0x01, 0x0a, 0x00, 0x00, 0x02},
+			want:    &ClockDrift{Drift: 0, WallClockDrift: 0, StationOffset: 7200, HostOffset: 7200},
+		},
+		{
+			name:    "Ahead",
+			station: []byte{0x07, 0xe6, 0x08, 0x01, 0x0a, 0x01, 0x05, 0x02},
+			want:    &ClockDrift{Drift: 65 * time.Second, WallClockDrift: 65 * time.Second, StationOffset: 7200, HostOffset: 7200},
+		},
+		{
+			name:    "OtherZone",
+			station: []byte{0x07, 0xe6, 0x08, 0x01, 0x09, 0x00, 0x00, 0x01},
+			want:    &ClockDrift{Drift: 0, WallClockDrift: -time.Hour, StationOffset: 3600, HostOffset: 7200},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			station, err := NewTimeData(tt.station)
+			if err != nil {
+				t.Fatalf("NewTimeData() error = %v", err)
+			}
+			got := NewClockDrift(station, host)
+			got.StationTime, got.HostTime = time.Time{}, time.Time{}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewClockDrift() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestTimeData_RawBytes(t *testing.T) {
 	l1 := time.FixedZone("UTC+1", +1*60*60)
 	t1, _ := time.Parse(time.RFC3339, "2020-12-28T15:43:23+01:00")
@@ -1962,3 +2058,248 @@ func TestTimeData_RawBytes(t *testing.T) {
 		})
 	}
 }
+
+func TestNewHistoryRecord(t *testing.T) {
+	type args struct {
+		raw []byte
+	}
+	tests := []struct {
+		name string
+		args args
+		want *HistoryRecord
+	}{
+		{
+			name: "2Channels",
+			args: args{
+				raw: []byte{0x07, 0xe4, 0x0c, 0x1c, 0x0f, 0x2b, 0x17,
+					0x00, 0x25, 0x46, 0x7f, 0xff, 0xff, 0xFF, 0xFB, 0x09, 0x7f, 0xff, 0xff,
+					0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff},
+			},
+			want: &HistoryRecord{
+				Time: time.Date(2020, 12, 28, 15, 43, 23, 0, time.Local),
+				Channels: []*ChannelData{
+					{
+						Number:      1,
+						Temperature: 3.7,
+						Humidity:    70,
+					},
+					{
+						Number:      3,
+						Temperature: -0.5,
+						Humidity:    9,
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewHistoryRecord(tt.args.raw)
+			if err != nil {
+				t.Fatalf("NewHistoryRecord() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewHistoryRecord() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryRecord_RawBytes(t *testing.T) {
+	tests := []struct {
+		name string
+		have *HistoryRecord
+		want []byte
+	}{
+		{
+			name: "2Channels",
+			have: &HistoryRecord{
+				Time: time.Date(2020, 12, 28, 15, 43, 23, 0, time.Local),
+				Channels: []*ChannelData{
+					{
+						Number:      1,
+						Temperature: 3.7,
+						Humidity:    70,
+					},
+					{
+						Number:      3,
+						Temperature: -0.5,
+						Humidity:    9,
+					},
+				},
+			},
+			want: []byte{0x07, 0xe4, 0x0c, 0x1c, 0x0f, 0x2b, 0x17,
+				0x00, 0x25, 0x46, 0x7f, 0xff, 0xff, 0xFF, 0xFB, 0x09, 0x7f, 0xff, 0xff,
+				0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.have
+			if got := d.RawBytes(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RawBytes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewExportData(t *testing.T) {
+	type args struct {
+		raw []byte
+	}
+	tests := []struct {
+		name string
+		args args
+		want ExportData
+	}{
+		{
+			name: "2Records",
+			args: args{
+				raw: []byte{
+					0x07, 0xe4, 0x0c, 0x1c, 0x0f, 0x2b, 0x17, 0x01, 0x00, 0x25, 0x46,
+					0x07, 0xe4, 0x0c, 0x1c, 0x0f, 0x2b, 0x17, 0x03, 0xFF, 0xFB, 0x09,
+				},
+			},
+			want: ExportData{
+				{
+					Time:        time.Date(2020, 12, 28, 15, 43, 23, 0, time.Local),
+					Channel:     1,
+					Temperature: 3.7,
+					Humidity:    70,
+				},
+				{
+					Time:        time.Date(2020, 12, 28, 15, 43, 23, 0, time.Local),
+					Channel:     3,
+					Temperature: -0.5,
+					Humidity:    9,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewExportData(tt.args.raw)
+			if err != nil {
+				t.Fatalf("NewExportData() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewExportData() = %v, want %v", got, tt.want)
+			}
+			if raw := got.RawBytes(); !reflect.DeepEqual(raw, tt.args.raw) {
+				t.Errorf("RawBytes() = %v, want %v", raw, tt.args.raw)
+			}
+		})
+	}
+}
+
+func TestExportData_WriteCSV(t *testing.T) {
+	l1 := time.FixedZone("UTC+1", +1*60*60)
+	d := ExportData{
+		{
+			Time:        time.Date(2020, 12, 28, 15, 43, 23, 0, l1),
+			Channel:     1,
+			Temperature: 3.7,
+			Humidity:    70,
+		},
+		{
+			Time:        time.Date(2020, 12, 28, 15, 48, 23, 0, l1),
+			Channel:     3,
+			Temperature: -0.5,
+			Humidity:    9,
+			Unit:        UnitFahrenheit,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := d.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+
+	want := "time,channel,temperature,humidity,unit\n" +
+		"2020-12-28T15:43:23+01:00,1,3.7,70,°C\n" +
+		"2020-12-28T15:48:23+01:00,3,-0.5,9,°F\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteCSV() = %q, want %q", got, want)
+	}
+}
+
+func TestNewData_InvalidSize(t *testing.T) {
+	tests := []struct {
+		name   string
+		decode func() error
+	}{
+		{"ChannelData", func() error { _, err := NewChannelData([]byte{0x00, 0x25}); return err }},
+		{"ChannelsData", func() error { _, err := NewChannelsData([]byte{0x00, 0x25, 0x46, 0x7f}); return err }},
+		{"IntervalData", func() error { _, err := NewIntervalData(nil); return err }},
+		{"LanguageData", func() error { _, err := NewLanguageData([]byte{}); return err }},
+		{"SettingsAreaData", func() error { _, err := NewSettingsAreaData([]byte{0x01, 0x02, 0x03}); return err }},
+		{"SettingsData", func() error { _, err := NewSettingsData(make([]byte, 26)); return err }},
+		{"AlarmSettingsData", func() error { _, err := NewAlarmSettingsData(make([]byte, 5)); return err }},
+		{"HumidityAlarmData", func() error { _, err := NewHumidityAlarmData([]byte{0x46}); return err }},
+		{"HumidityAlarmsData", func() error { _, err := NewHumidityAlarmsData(make([]byte, 15)); return err }},
+		{"TemperatureAlarmData", func() error { _, err := NewTemperatureAlarmData(make([]byte, 3)); return err }},
+		{"TemperatureAlarmsData", func() error { _, err := NewTemperatureAlarmsData(make([]byte, 31)); return err }},
+		{"CalibrationData", func() error { _, err := NewCalibrationData(make([]byte, 2)); return err }},
+		{"CalibrationsData", func() error { _, err := NewCalibrationsData(make([]byte, 23)); return err }},
+		{"TimeData", func() error { _, err := NewTimeData(make([]byte, 7)); return err }},
+		{"HistoryRecord", func() error { _, err := NewHistoryRecord(make([]byte, 30)); return err }},
+		{"HistoryRecords", func() error { _, err := NewHistoryRecords(make([]byte, 32)); return err }},
+		{"ExportRecord", func() error { _, err := NewExportRecord(make([]byte, 10)); return err }},
+		{"ExportData", func() error { _, err := NewExportData(make([]byte, 12)); return err }},
+		{"FilePageData", func() error { _, err := NewFilePageData([]byte{0x00}); return err }},
+		{"FilePageRequestData", func() error { _, err := NewFilePageRequestData(nil); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.decode(); !errors.Is(err, ErrBadFrame) {
+				t.Errorf("New%s() error = %v, want %v", tt.name, err, ErrBadFrame)
+			}
+		})
+	}
+}
+
+func TestParseUnit(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Unit
+		wantErr bool
+	}{
+		{"celsius", UnitCelsius, false},
+		{"Fahrenheit", UnitFahrenheit, false},
+		{"°F", UnitFahrenheit, false},
+		{"C", UnitCelsius, false},
+		{"kelvin", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseUnit(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseUnit() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseUnit() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelData_ConvertTo(t *testing.T) {
+	tests := []struct {
+		name string
+		have *ChannelData
+		unit Unit
+		want *ChannelData
+	}{
+		{"ToFahrenheit", &ChannelData{Number: 1, Temperature: -0.5, Humidity: 9}, UnitFahrenheit, &ChannelData{Number: 1, Temperature: 31.1, Humidity: 9, Unit: UnitFahrenheit}},
+		{"ToCelsius", &ChannelData{Number: 2, Temperature: 98.6, Humidity: 40, Unit: UnitFahrenheit}, UnitCelsius, &ChannelData{Number: 2, Temperature: 37, Humidity: 40, Unit: UnitCelsius}},
+		{"Same", &ChannelData{Number: 3, Temperature: 21.3, Humidity: 50}, UnitCelsius, &ChannelData{Number: 3, Temperature: 21.3, Humidity: 50}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.have.ConvertTo(tt.unit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConvertTo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package pkg
+
+import "math"
+
+// DerivedData are the values that are computed on the host from the temperature and humidity of a channel.
+// Dew point and heat index use the temperature unit of the channel.
+type DerivedData struct {
+	DewPoint         float64
+	HeatIndex        float64
+	AbsoluteHumidity float64 // g/m³
+	VapourPressure   float64 // hPa
+}
+
+// NewDerivedData computes the derived values of a channel. The station reports the humidity in whole percent,
+// a humidity of 0 % is computed as 1 % to keep the dew point finite.
+func NewDerivedData(channel *ChannelData) *DerivedData {
+	temperature := ConvertTemperature(channel.Temperature, channel.Unit, UnitCelsius)
+	humidity := math.Min(math.Max(channel.Humidity, 1), 100)
+
+	d := &DerivedData{}
+	d.VapourPressure = humidity / 100 * saturationVapourPressure(temperature)
+	d.AbsoluteHumidity = 216.7 * d.VapourPressure / (273.15 + temperature) // ideal gas law for water vapour
+	d.DewPoint = dewPoint(d.VapourPressure)
+	d.HeatIndex = heatIndex(temperature, humidity)
+	d.DewPoint = ConvertTemperature(d.DewPoint, UnitCelsius, channel.Unit)
+	d.HeatIndex = ConvertTemperature(d.HeatIndex, UnitCelsius, channel.Unit)
+
+	d.DewPoint = roundTenth(d.DewPoint)
+	d.HeatIndex = roundTenth(d.HeatIndex)
+	d.AbsoluteHumidity = roundTenth(d.AbsoluteHumidity)
+	d.VapourPressure = roundTenth(d.VapourPressure)
+
+	return d
+}
+
+// Magnus formula coefficients over water (Sonntag 1990), valid from -45 °C to 60 °C.
+const (
+	magnusA = 6.112 // hPa
+	magnusB = 17.62
+	magnusC = 243.12 // °C
+)
+
+// saturationVapourPressure returns the saturation vapour pressure in hPa at the temperature in °C.
+func saturationVapourPressure(temperature float64) float64 {
+	return magnusA * math.Exp(magnusB*temperature/(magnusC+temperature))
+}
+
+// dewPoint returns the dew point in °C for the vapour pressure in hPa, it is the inverse of the Magnus formula.
+func dewPoint(vapourPressure float64) float64 {
+	x := math.Log(vapourPressure / magnusA)
+	return magnusC * x / (magnusB - x)
+}
+
+// heatIndex returns the heat index in °C, using the algorithm of the US National Weather Service. The heat index is
+// not defined below 80 °F (26.7 °C), the temperature is returned instead.
+func heatIndex(temperature, humidity float64) float64 {
+	t := temperature*9/5 + 32 // the regression is defined in °F
+	if t < 80 {
+		return temperature
+	}
+
+	hi := 0.5 * (t + 61 + (t-68)*1.2 + humidity*0.094)
+	if (hi+t)/2 >= 80 {
+		hi = -42.379 + 2.04901523*t + 10.14333127*humidity - 0.22475541*t*humidity - 0.00683783*t*t -
+			0.05481717*humidity*humidity + 0.00122874*t*t*humidity + 0.00085282*t*humidity*humidity -
+			0.00000199*t*t*humidity*humidity
+		switch {
+		case humidity < 13 && t >= 80 && t <= 112:
+			hi -= (13 - humidity) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+		case humidity > 85 && t >= 80 && t <= 87:
+			hi += (humidity - 85) / 10 * (87 - t) / 5
+		}
+	}
+
+	return (hi - 32) * 5 / 9
+}
+
+func roundTenth(value float64) float64 {
+	return math.Round(value*10) / 10
+}
@@ -0,0 +1,47 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewDerivedData(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel *ChannelData
+		want    *DerivedData
+	}{
+		{
+			name:    "Room",
+			channel: &ChannelData{Number: 1, Temperature: 20, Humidity: 50, Unit: UnitCelsius},
+			want:    &DerivedData{DewPoint: 9.3, HeatIndex: 20, AbsoluteHumidity: 8.6, VapourPressure: 11.7},
+		},
+		{
+			name:    "Hot",
+			channel: &ChannelData{Number: 2, Temperature: 32.2, Humidity: 70, Unit: UnitCelsius},
+			want:    &DerivedData{DewPoint: 26, HeatIndex: 41, AbsoluteHumidity: 23.8, VapourPressure: 33.6},
+		},
+		{
+			name:    "HotFahrenheit",
+			channel: &ChannelData{Number: 2, Temperature: 90, Humidity: 70, Unit: UnitFahrenheit},
+			want:    &DerivedData{DewPoint: 78.9, HeatIndex: 105.9, AbsoluteHumidity: 23.9, VapourPressure: 33.6},
+		},
+		{
+			name:    "Frost",
+			channel: &ChannelData{Number: 3, Temperature: -5, Humidity: 80, Unit: UnitCelsius},
+			want:    &DerivedData{DewPoint: -7.9, HeatIndex: -5, AbsoluteHumidity: 2.7, VapourPressure: 3.4},
+		},
+		{
+			name:    "Dry",
+			channel: &ChannelData{Number: 4, Temperature: 0, Humidity: 0, Unit: UnitCelsius},
+			want:    &DerivedData{DewPoint: -50.4, HeatIndex: 0, AbsoluteHumidity: 0, VapourPressure: 0.1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewDerivedData(tt.channel); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewDerivedData() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
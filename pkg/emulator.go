@@ -0,0 +1,543 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/h44z/dntroomloggpro-go/internal"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	emulatorChannels     = 8
+	emulatorPacketSize   = 64   // max packet size of the USB endpoints
+	emulatorPageSize     = 1024 // max number of record bytes in one file page, a page spans multiple packets
+	emulatorHistoryLimit = 4096 // max number of records kept in the emulated SD card
+)
+
+// EmulatedSensor is the simulated, uncalibrated state of one sensor channel.
+type EmulatedSensor struct {
+	Temperature float64
+	Humidity    float64
+	Online      bool
+}
+
+// Emulator is an emulated DNT RoomLogg PRO base station. It implements the Transport interface and answers
+// requests with the same wire format as the real device. Settings, alarms and calibration data persist across
+// set and get commands, calibration offsets are applied to the simulated sensor values.
+type Emulator struct {
+	mux sync.Mutex
+
+	isOpen     bool
+	present    bool // false if the emulated device is unplugged
+	storeOpen  bool // set by CommandStartStore, most set commands are only accepted afterwards
+	drift      float64
+	rnd        *rand.Rand
+	clockDelta time.Duration // difference between the emulated clock and the host clock
+	clockZone  *time.Location
+
+	sensors           [emulatorChannels]*EmulatedSensor
+	interval          IntervalData
+	language          LanguageData
+	settings          *SettingsData
+	alarmSettings     *AlarmSettingsData
+	calibration       []*CalibrationData
+	temperatureAlarms []*TemperatureAlarmData
+	humidityAlarms    []*HumidityAlarmData
+
+	history     []*HistoryRecord
+	lastHistory time.Time // station time of the last logged history record
+}
+
+// NewEmulator creates a new emulated base station with four online channels and factory default settings.
+func NewEmulator() *Emulator {
+	e := &Emulator{
+		rnd:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		present:   true,
+		clockZone: time.Local,
+		interval:  IntervalData(5),
+		language:  LanguageData(LanguageEN),
+	}
+	e.lastHistory = e.now()
+
+	defaults := []EmulatedSensor{
+		{Temperature: 21.5, Humidity: 45, Online: true},
+		{Temperature: 19.2, Humidity: 52, Online: true},
+		{Temperature: 23.8, Humidity: 38, Online: true},
+		{Temperature: 4.6, Humidity: 81, Online: true},
+	}
+	for i := 0; i < emulatorChannels; i++ {
+		e.sensors[i] = &EmulatedSensor{}
+		if i < len(defaults) {
+			*e.sensors[i] = defaults[i]
+		}
+	}
+
+	e.settings = &SettingsData{
+		GraphType:     GraphTypeTemperature,
+		GraphInterval: GraphInterval24h,
+		TimeFormat:    TimeFormatEurope,
+		DateFormat:    DateFormatDDMMYYYY,
+		DST:           DSTOff,
+		TimeZone:      0,
+		Units:         UnitCelsius,
+	}
+	for i := 0; i < len(e.settings.Areas); i++ {
+		e.settings.Areas[i], _ = NewSettingsAreaData(make([]byte, 4))
+		e.settings.Areas[i].Area = i + 1
+	}
+	e.alarmSettings, _ = NewAlarmSettingsData([]byte{byte(AlarmOff), byte(AlarmOff), 0x00, 0x00, 0x00, 0x00})
+	e.calibration, _ = NewCalibrationsData(make([]byte, 3*emulatorChannels))
+	e.temperatureAlarms, _ = NewTemperatureAlarmsData(make([]byte, 4*emulatorChannels))
+	for _, alarm := range e.temperatureAlarms {
+		alarm.High = 30
+		alarm.Low = 10
+	}
+	e.humidityAlarms, _ = NewHumidityAlarmsData(make([]byte, 2*emulatorChannels))
+	for _, alarm := range e.humidityAlarms {
+		alarm.High = 70
+		alarm.Low = 30
+	}
+
+	return e
+}
+
+// SetSensor updates the simulated, uncalibrated values (°C) of the given channel (1 based) and marks it as online.
+func (e *Emulator) SetSensor(channel int, temperature, humidity float64) error {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	if channel < 1 || channel > emulatorChannels {
+		return errors.New("channel out of range")
+	}
+
+	e.sensors[channel-1] = &EmulatedSensor{Temperature: temperature, Humidity: humidity, Online: true}
+
+	return nil
+}
+
+// SetSensorOffline marks the given channel (1 based) as offline, the station reports a humidity of 0xff for it.
+func (e *Emulator) SetSensorOffline(channel int) error {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	if channel < 1 || channel > emulatorChannels {
+		return errors.New("channel out of range")
+	}
+
+	e.sensors[channel-1].Online = false
+
+	return nil
+}
+
+// Sensor returns a copy of the simulated state of the given channel (1 based).
+func (e *Emulator) Sensor(channel int) (EmulatedSensor, error) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	if channel < 1 || channel > emulatorChannels {
+		return EmulatedSensor{}, errors.New("channel out of range")
+	}
+
+	return *e.sensors[channel-1], nil
+}
+
+// SetDrift enables a random walk of the simulated sensor values. Each current data request changes the temperature
+// by up to step degrees and the humidity by up to 10*step percent. A step of 0 disables the drift.
+func (e *Emulator) SetDrift(step float64) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	e.drift = step
+}
+
+// Now returns the current time of the emulated station clock.
+func (e *Emulator) Now() time.Time {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	return e.now()
+}
+
+func (e *Emulator) now() time.Time {
+	return time.Now().Add(e.clockDelta).In(e.clockZone)
+}
+
+// RecordHistory logs the current calibrated sensor values to the emulated SD card, using the emulated station clock
+// as timestamp. The station also logs the values on its own after each logging interval.
+func (e *Emulator) RecordHistory() {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	e.recordHistory(e.now())
+}
+
+func (e *Emulator) recordHistory(t time.Time) {
+	channels, _ := NewChannelsData(e.currentData())
+	record := &HistoryRecord{
+		Time:     time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local),
+		Channels: channels,
+	}
+	e.history = append(e.history, record)
+	if len(e.history) > emulatorHistoryLimit {
+		e.history = e.history[len(e.history)-emulatorHistoryLimit:]
+	}
+	e.lastHistory = t
+}
+
+// updateHistory logs a record for each logging interval that elapsed since the last record.
+func (e *Emulator) updateHistory() {
+	if e.interval == 0 {
+		return
+	}
+
+	interval := time.Duration(e.interval) * time.Minute
+	now := e.now()
+	if now.Sub(e.lastHistory) > emulatorHistoryLimit*interval {
+		e.lastHistory = now.Add(-emulatorHistoryLimit * interval) // older records would be dropped anyway
+	}
+	for !e.lastHistory.Add(interval).After(now) {
+		e.recordHistory(e.lastHistory.Add(interval))
+	}
+}
+
+// SetPresent simulates plugging in (true) or removing (false) the emulated device.
+// While the device is removed, all requests fail and it can not be opened.
+func (e *Emulator) SetPresent(present bool) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	e.present = present
+	if !present {
+		e.isOpen = false
+		e.storeOpen = false
+	}
+}
+
+// WatchPresence reports whenever the emulated device is plugged in or removed.
+func (e *Emulator) WatchPresence(interval time.Duration, done <-chan struct{}) <-chan bool {
+	events := make(chan bool, 1)
+
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		first := true
+		known := false
+		for {
+			e.mux.Lock()
+			present := e.present
+			e.mux.Unlock()
+
+			if first || present != known {
+				select {
+				case events <- present:
+				case <-done:
+					return
+				}
+				first = false
+				known = present
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return events
+}
+
+func (e *Emulator) Open() error {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	if !e.present {
+		return fmt.Errorf("%w: emulated device is unplugged", internal.ErrDeviceNotFound)
+	}
+	e.isOpen = true
+
+	return nil
+}
+
+func (e *Emulator) Close() {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	e.isOpen = false
+	e.storeOpen = false
+}
+
+func (e *Emulator) Request(ctx context.Context, command byte, payload []byte, noResponse ...bool) ([]byte, error) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("emulated request for command 0x%02x aborted: %w", command, err)
+	}
+
+	if !e.isOpen {
+		return nil, fmt.Errorf("%w: emulated base station is not open", internal.ErrDisconnected)
+	}
+
+	logrus.Tracef("Emulator received command 0x%02x with %d bytes payload", command, len(payload))
+
+	e.updateHistory()
+
+	response, err := e.handle(command, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if noResponse != nil && len(noResponse) > 0 && noResponse[0] == true {
+		return nil, nil
+	}
+	if response == nil {
+		// The real device does not answer set commands, so the read would run into a timeout.
+		return nil, fmt.Errorf("%w: no response for command 0x%02x", internal.ErrTimeout, command)
+	}
+
+	return frame(response), nil
+}
+
+// handle applies the command to the emulated state and returns the response payload, nil if the station does not
+// respond to the command.
+func (e *Emulator) handle(command byte, payload []byte) ([]byte, error) {
+	switch command {
+	case CommandGetCurrentData:
+		e.driftSensors()
+		return e.currentData(), nil
+	case CommandGetSettings:
+		return e.settings.RawBytes(), nil
+	case CommandGetAlarmSettings:
+		return e.alarmSettings.RawBytes(), nil
+	case CommandGetCalibration:
+		return concatRawBytes(e.calibration), nil
+	case CommandGetTemperatureAlarm:
+		return concatRawBytes(e.temperatureAlarms), nil
+	case CommandGetHumidityAlarm:
+		return concatRawBytes(e.humidityAlarms), nil
+	case CommandGetInterval:
+		return e.interval.RawBytes(), nil
+	case CommandGetTime:
+		t := TimeData{time: e.now(), dst: e.settings.DST == DSTOn}
+		return t.RawBytes(), nil
+	case CommandGetSDCardFile:
+		page, err := NewFilePageRequestData(payload)
+		if err != nil {
+			return nil, err
+		}
+		return e.historyPage(page), nil
+	case CommandGetExportFile:
+		page, err := NewFilePageRequestData(payload)
+		if err != nil {
+			return nil, err
+		}
+		return e.exportPage(page), nil
+
+	case CommandStartStore:
+		e.storeOpen = true
+		return []byte{0x00}, nil
+	case CommandEndStore:
+		e.storeOpen = false
+		return nil, nil
+
+	case CommandSetInterval: // interval sync has no start-store command
+		interval, err := NewIntervalData(payload)
+		if err != nil {
+			return nil, err
+		}
+		e.interval = interval
+		return nil, nil
+	case CommandSetTime: // time sync has no end-store command
+		if !e.acceptStore(command) {
+			return nil, nil
+		}
+		t, err := newTimeData(payload, e.settings.DST == DSTOn)
+		if err != nil {
+			return nil, err
+		}
+		e.clockDelta = time.Until(t.time)
+		e.clockZone = t.time.Location()
+		e.storeOpen = false
+		return nil, nil
+	case CommandSetLanguage:
+		if !e.acceptStore(command) {
+			return nil, nil
+		}
+		language, err := NewLanguageData(payload)
+		if err != nil {
+			return nil, err
+		}
+		e.language = language
+		return nil, nil
+	case CommandSetSettings:
+		if !e.acceptStore(command) {
+			return nil, nil
+		}
+		settings, err := NewSettingsData(payload)
+		if err != nil {
+			return nil, err
+		}
+		e.settings = settings
+		return nil, nil
+	case CommandSetAlarmSettings:
+		if !e.acceptStore(command) {
+			return nil, nil
+		}
+		alarmSettings, err := NewAlarmSettingsData(payload)
+		if err != nil {
+			return nil, err
+		}
+		e.alarmSettings = alarmSettings
+		return nil, nil
+	case CommandSetCalibration:
+		if !e.acceptStore(command) {
+			return nil, nil
+		}
+		if len(payload) < 3*emulatorChannels {
+			return nil, errors.New("invalid calibration payload size")
+		}
+		e.calibration, _ = NewCalibrationsData(payload[:3*emulatorChannels])
+		return nil, nil
+	case CommandSetTemperatureAlarm:
+		if !e.acceptStore(command) {
+			return nil, nil
+		}
+		if len(payload) < 4*emulatorChannels {
+			return nil, errors.New("invalid temperature alarm payload size")
+		}
+		e.temperatureAlarms, _ = NewTemperatureAlarmsData(payload[:4*emulatorChannels])
+		return nil, nil
+	case CommandSetHumidityAlarm:
+		if !e.acceptStore(command) {
+			return nil, nil
+		}
+		if len(payload) < 2*emulatorChannels {
+			return nil, errors.New("invalid humidity alarm payload size")
+		}
+		e.humidityAlarms, _ = NewHumidityAlarmsData(payload[:2*emulatorChannels])
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unsupported command 0x%02x", command)
+}
+
+// acceptStore checks if a store sequence was started, the station silently ignores set commands otherwise.
+func (e *Emulator) acceptStore(command byte) bool {
+	if !e.storeOpen {
+		logrus.Warnf("Emulator ignored command 0x%02x, no store sequence started", command)
+		return false
+	}
+	return true
+}
+
+// driftSensors applies one step of the random walk to all online sensors.
+func (e *Emulator) driftSensors() {
+	if e.drift <= 0 {
+		return
+	}
+
+	for _, sensor := range e.sensors {
+		if !sensor.Online {
+			continue
+		}
+		sensor.Temperature += (e.rnd.Float64()*2 - 1) * e.drift
+		sensor.Humidity = clamp(sensor.Humidity+(e.rnd.Float64()*2-1)*e.drift*10, 1, 99)
+	}
+}
+
+// currentData returns the calibrated channel data of all sensors, in the unit of the station settings.
+func (e *Emulator) currentData() []byte {
+	raw := make([]byte, 0, 3*emulatorChannels)
+	for i, sensor := range e.sensors {
+		if !sensor.Online {
+			raw = append(raw, 0x7f, 0xff, 0xff)
+			continue
+		}
+
+		channel := &ChannelData{
+			Number:      i + 1,
+			Temperature: ConvertTemperature(sensor.Temperature, UnitCelsius, e.settings.Units) + e.calibration[i].Temperature,
+			Humidity:    clamp(math.Round(sensor.Humidity+e.calibration[i].Humidity), 1, 99),
+		}
+		raw = append(raw, channel.RawBytes()...)
+	}
+
+	return raw
+}
+
+// historyPage returns the requested page of the SD card file.
+func (e *Emulator) historyPage(page FilePageRequestData) []byte {
+	records := make([]Data, len(e.history))
+	for i, record := range e.history {
+		records[i] = record
+	}
+
+	return filePage(records, historyRecordSize, page)
+}
+
+// exportPage returns the requested page of the export file, it contains one record per logged channel value.
+func (e *Emulator) exportPage(page FilePageRequestData) []byte {
+	records := make([]Data, 0, len(e.history))
+	for _, record := range e.history {
+		for _, channel := range record.Channels {
+			records = append(records, &ExportRecord{
+				Time:        record.Time,
+				Channel:     channel.Number,
+				Temperature: channel.Temperature,
+				Humidity:    channel.Humidity,
+			})
+		}
+	}
+
+	return filePage(records, exportRecordSize, page)
+}
+
+// filePage returns the requested page of a file transfer.
+func filePage(records []Data, recordSize int, page FilePageRequestData) []byte {
+	recordsPerPage := emulatorPageSize / recordSize
+	pageData := &FilePageData{
+		Pages: (len(records) + recordsPerPage - 1) / recordsPerPage,
+	}
+
+	start := int(page) * recordsPerPage
+	for i := start; i < start+recordsPerPage && i < len(records); i++ {
+		pageData.Records = append(pageData.Records, records[i].RawBytes()...)
+	}
+
+	return pageData.RawBytes()
+}
+
+func concatRawBytes[T Data](data []T) []byte {
+	raw := make([]byte, 0)
+	for _, d := range data {
+		raw = append(raw, d.RawBytes()...)
+	}
+	return raw
+}
+
+func clamp(v, lower, upper float64) float64 {
+	return math.Max(lower, math.Min(upper, v))
+}
+
+// frame wraps the payload in the message start and end bytes and pads it to full USB packets.
+func frame(payload []byte) []byte {
+	raw := make([]byte, 0, emulatorPacketSize)
+	raw = append(raw, internal.MessageStart...)
+	raw = append(raw, payload...)
+	raw = append(raw, internal.MessageEnd...)
+	for len(raw)%emulatorPacketSize != 0 {
+		raw = append(raw, 0)
+	}
+	return raw
+}
@@ -0,0 +1,380 @@
+package pkg
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newEmulatedRoomLogg(t *testing.T) (*RoomLogg, *Emulator) {
+	t.Helper()
+
+	e := NewEmulator()
+	r := NewRoomLoggWithTransport(&RoomLoggConfig{PollingRate: 60}, e)
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	return r, e
+}
+
+func TestEmulator_CurrentData(t *testing.T) {
+	r, e := newEmulatedRoomLogg(t)
+	defer r.Close()
+
+	_ = e.SetSensor(1, 22.3, 40)
+	_ = e.SetSensor(2, -7.4, 88)
+	_ = e.SetSensorOffline(3)
+	_ = e.SetSensorOffline(4)
+
+	got, err := r.FetchCurrentData()
+	if err != nil {
+		t.Fatalf("FetchCurrentData() error = %v", err)
+	}
+	want := []*ChannelData{
+		{Number: 1, Temperature: 22.3, Humidity: 40},
+		{Number: 2, Temperature: -7.4, Humidity: 88},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FetchCurrentData() got = %v, want %v", got, want)
+	}
+}
+
+func TestEmulator_Calibration(t *testing.T) {
+	r, e := newEmulatedRoomLogg(t)
+	defer r.Close()
+
+	_ = e.SetSensor(1, 20, 50)
+
+	calibration, err := r.FetchCalibrationData()
+	if err != nil {
+		t.Fatalf("FetchCalibrationData() error = %v", err)
+	}
+	calibration[0].Temperature = -1.5
+	calibration[0].Humidity = 3
+	if err := r.SetCalibrationData(calibration); err != nil {
+		t.Fatalf("SetCalibrationData() error = %v", err)
+	}
+
+	got, err := r.FetchCalibrationData()
+	if err != nil {
+		t.Fatalf("FetchCalibrationData() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, calibration) {
+		t.Errorf("FetchCalibrationData() got = %v, want %v", got, calibration)
+	}
+
+	channels, err := r.FetchCurrentData()
+	if err != nil {
+		t.Fatalf("FetchCurrentData() error = %v", err)
+	}
+	want := &ChannelData{Number: 1, Temperature: 18.5, Humidity: 53}
+	if !reflect.DeepEqual(channels[0], want) {
+		t.Errorf("FetchCurrentData() got = %v, want %v", channels[0], want)
+	}
+}
+
+func TestEmulator_SettingsPersist(t *testing.T) {
+	r, _ := newEmulatedRoomLogg(t)
+	defer r.Close()
+
+	settings, err := r.FetchSettings()
+	if err != nil {
+		t.Fatalf("FetchSettings() error = %v", err)
+	}
+	settings.Units = UnitFahrenheit
+	settings.TimeZone = -5
+	settings.Areas[1].Temperature[2] = true
+	if err := r.SetSettings(settings); err != nil {
+		t.Fatalf("SetSettings() error = %v", err)
+	}
+
+	got, err := r.FetchSettings()
+	if err != nil {
+		t.Fatalf("FetchSettings() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, settings) {
+		t.Errorf("FetchSettings() got = %v, want %v", got, settings)
+	}
+}
+
+func TestEmulator_TemperatureUnit(t *testing.T) {
+	tests := []struct {
+		name            string
+		temperatureUnit string
+		want            []*ChannelData
+	}{
+		{"StationUnit", "", []*ChannelData{{Number: 1, Temperature: 68, Humidity: 40, Unit: UnitFahrenheit}}},
+		{"Canonical", "celsius", []*ChannelData{{Number: 1, Temperature: 20, Humidity: 40, Unit: UnitCelsius}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEmulator()
+			r := NewRoomLoggWithTransport(&RoomLoggConfig{PollingRate: 60, TemperatureUnit: tt.temperatureUnit}, e)
+			if err := r.Open(); err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer r.Close()
+
+			_ = e.SetSensor(1, 20, 40)
+			for channel := 2; channel <= emulatorChannels; channel++ {
+				_ = e.SetSensorOffline(channel)
+			}
+			settings, err := r.FetchSettings()
+			if err != nil {
+				t.Fatalf("FetchSettings() error = %v", err)
+			}
+			settings.Units = UnitFahrenheit
+			if err := r.SetSettings(settings); err != nil {
+				t.Fatalf("SetSettings() error = %v", err)
+			}
+
+			got, err := r.FetchCurrentData()
+			if err != nil {
+				t.Fatalf("FetchCurrentData() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FetchCurrentData() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmulator_AlarmsPersist(t *testing.T) {
+	r, _ := newEmulatedRoomLogg(t)
+	defer r.Close()
+
+	alarmSettings, err := r.FetchAlarmSettings()
+	if err != nil {
+		t.Fatalf("FetchAlarmSettings() error = %v", err)
+	}
+	alarmSettings.EnableTemperatureAlarm = AlarmOn
+	alarmSettings.TemperatureHighAlarm[0] = true
+	if err := r.SetAlarmSettings(alarmSettings); err != nil {
+		t.Fatalf("SetAlarmSettings() error = %v", err)
+	}
+	gotSettings, err := r.FetchAlarmSettings()
+	if err != nil {
+		t.Fatalf("FetchAlarmSettings() error = %v", err)
+	}
+	if !reflect.DeepEqual(gotSettings, alarmSettings) {
+		t.Errorf("FetchAlarmSettings() got = %v, want %v", gotSettings, alarmSettings)
+	}
+
+	temperatureAlarms, err := r.FetchTemperatureAlarms()
+	if err != nil {
+		t.Fatalf("FetchTemperatureAlarms() error = %v", err)
+	}
+	temperatureAlarms[3].Low = -12.5
+	if err := r.SetTemperatureAlarms(temperatureAlarms); err != nil {
+		t.Fatalf("SetTemperatureAlarms() error = %v", err)
+	}
+	gotTemperatureAlarms, err := r.FetchTemperatureAlarms()
+	if err != nil {
+		t.Fatalf("FetchTemperatureAlarms() error = %v", err)
+	}
+	if !reflect.DeepEqual(gotTemperatureAlarms, temperatureAlarms) {
+		t.Errorf("FetchTemperatureAlarms() got = %v, want %v", gotTemperatureAlarms, temperatureAlarms)
+	}
+
+	humidityAlarms, err := r.FetchHumidityAlarms()
+	if err != nil {
+		t.Fatalf("FetchHumidityAlarms() error = %v", err)
+	}
+	humidityAlarms[7].High = 95
+	if err := r.SetHumidityAlarms(humidityAlarms); err != nil {
+		t.Fatalf("SetHumidityAlarms() error = %v", err)
+	}
+	gotHumidityAlarms, err := r.FetchHumidityAlarms()
+	if err != nil {
+		t.Fatalf("FetchHumidityAlarms() error = %v", err)
+	}
+	if !reflect.DeepEqual(gotHumidityAlarms, humidityAlarms) {
+		t.Errorf("FetchHumidityAlarms() got = %v, want %v", gotHumidityAlarms, humidityAlarms)
+	}
+}
+
+func TestEmulator_SetWithoutStore(t *testing.T) {
+	r, e := newEmulatedRoomLogg(t)
+	defer r.Close()
+
+	// A set command without a preceding start-store command is ignored by the station
+	if _, err := e.Request(context.Background(), CommandSetSettings, make([]byte, 27), true); err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+
+	settings, err := r.FetchSettings()
+	if err != nil {
+		t.Fatalf("FetchSettings() error = %v", err)
+	}
+	if settings.GraphInterval != GraphInterval24h {
+		t.Errorf("FetchSettings() got = %v, want %v", settings.GraphInterval, GraphInterval24h)
+	}
+}
+
+func TestEmulator_Interval(t *testing.T) {
+	r, _ := newEmulatedRoomLogg(t)
+	defer r.Close()
+
+	if err := r.SetIntervalMinutes(30); err != nil {
+		t.Fatalf("SetIntervalMinutes() error = %v", err)
+	}
+
+	got, err := r.FetchIntervalMinutes()
+	if err != nil {
+		t.Fatalf("FetchIntervalMinutes() error = %v", err)
+	}
+	if got != 30 {
+		t.Errorf("FetchIntervalMinutes() got = %v, want %v", got, 30)
+	}
+}
+
+func TestEmulator_ClockDrift(t *testing.T) {
+	r, _ := newEmulatedRoomLogg(t)
+	defer r.Close()
+	r.cfg.GetTime = true
+
+	zone := time.FixedZone("UTC+2", 2*60*60)
+	if err := r.SetTime(TimeData{time: time.Now().Add(90 * time.Second).In(zone)}); err != nil {
+		t.Fatalf("SetTime() error = %v", err)
+	}
+
+	got, err := r.FetchClockDrift()
+	if err != nil {
+		t.Fatalf("FetchClockDrift() error = %v", err)
+	}
+	if got.Drift < 88*time.Second || got.Drift > 91*time.Second {
+		t.Errorf("FetchClockDrift() drift = %v, want about %v", got.Drift, 90*time.Second)
+	}
+	if got.StationOffset != 2*60*60 {
+		t.Errorf("FetchClockDrift() station offset = %v, want %v", got.StationOffset, 2*60*60)
+	}
+	if want := got.Drift + time.Duration(got.StationOffset-got.HostOffset)*time.Second; got.WallClockDrift != want {
+		t.Errorf("FetchClockDrift() wall clock drift = %v, want %v", got.WallClockDrift, want)
+	}
+}
+
+func TestEmulator_SDCardHistory(t *testing.T) {
+	r, e := newEmulatedRoomLogg(t)
+	defer r.Close()
+	r.cfg.SDCard = true
+
+	_ = e.SetSensor(1, 20, 50)
+	e.RecordHistory()
+	_ = e.SetSensor(1, 21, 51)
+	_ = e.SetSensorOffline(2)
+	e.RecordHistory()
+
+	got, err := r.FetchSDCardHistory()
+	if err != nil {
+		t.Fatalf("FetchSDCardHistory() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("FetchSDCardHistory() got %d records, want %d", len(got), 2)
+	}
+	if got[0].Channels[0].Temperature != 20 || len(got[0].Channels) != 4 {
+		t.Errorf("FetchSDCardHistory() got first record %v", got[0].Channels)
+	}
+	if got[1].Channels[0].Temperature != 21 || len(got[1].Channels) != 3 {
+		t.Errorf("FetchSDCardHistory() got second record %v", got[1].Channels)
+	}
+}
+
+func TestEmulator_ExportFile(t *testing.T) {
+	r, e := newEmulatedRoomLogg(t)
+	defer r.Close()
+	r.cfg.ExportFile = true
+
+	_ = e.SetSensor(1, 20, 50)
+	_ = e.SetSensor(2, -2.5, 75)
+	_ = e.SetSensorOffline(3)
+	_ = e.SetSensorOffline(4)
+	e.RecordHistory()
+	e.RecordHistory()
+	e.RecordHistory()
+
+	got, err := r.FetchExportFile()
+	if err != nil {
+		t.Fatalf("FetchExportFile() error = %v", err)
+	}
+	if len(got) != 6 {
+		t.Fatalf("FetchExportFile() got %d records, want %d", len(got), 6)
+	}
+	for i, record := range got {
+		want := &ExportRecord{Time: record.Time, Channel: 1, Temperature: 20, Humidity: 50}
+		if i%2 == 1 {
+			want = &ExportRecord{Time: record.Time, Channel: 2, Temperature: -2.5, Humidity: 75}
+		}
+		if !reflect.DeepEqual(record, want) {
+			t.Errorf("FetchExportFile() record %d = %v, want %v", i, record, want)
+		}
+	}
+}
+
+func TestEmulator_FileRecordsUnit(t *testing.T) {
+	r, e := newEmulatedRoomLogg(t)
+	defer r.Close()
+	r.cfg.ExportFile = true
+	r.cfg.SDCard = true
+
+	settings, err := r.FetchSettings()
+	if err != nil {
+		t.Fatalf("FetchSettings() error = %v", err)
+	}
+	settings.Units = UnitFahrenheit
+	if err := r.SetSettings(settings); err != nil {
+		t.Fatalf("SetSettings() error = %v", err)
+	}
+	_ = e.SetSensor(1, 20, 50)
+	e.RecordHistory()
+
+	tests := []struct {
+		name     string
+		unit     string
+		wantUnit Unit
+		wantTemp float64
+	}{
+		{"StationUnit", "", UnitFahrenheit, 68},
+		{"Converted", "celsius", UnitCelsius, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r.cfg.TemperatureUnit = tt.unit
+
+			history, err := r.FetchSDCardHistory()
+			if err != nil {
+				t.Fatalf("FetchSDCardHistory() error = %v", err)
+			}
+			if got := history[0].Channels[0]; got.Unit != tt.wantUnit || got.Temperature != tt.wantTemp {
+				t.Errorf("FetchSDCardHistory() got = %v, want %v %v", got, tt.wantTemp, tt.wantUnit)
+			}
+
+			export, err := r.FetchExportFile()
+			if err != nil {
+				t.Fatalf("FetchExportFile() error = %v", err)
+			}
+			if got := export[0]; got.Unit != tt.wantUnit || got.Temperature != tt.wantTemp {
+				t.Errorf("FetchExportFile() got = %v, want %v %v", got, tt.wantTemp, tt.wantUnit)
+			}
+		})
+	}
+}
+
+func TestEmulator_SDCardHistoryMultiPage(t *testing.T) {
+	r, e := newEmulatedRoomLogg(t)
+	defer r.Close()
+	r.cfg.SDCard = true
+
+	for i := 0; i < 100; i++ {
+		e.RecordHistory()
+	}
+
+	got, err := r.FetchSDCardHistory()
+	if err != nil {
+		t.Fatalf("FetchSDCardHistory() error = %v", err)
+	}
+	if len(got) != 100 {
+		t.Errorf("FetchSDCardHistory() got %d records, want %d", len(got), 100)
+	}
+}
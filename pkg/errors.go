@@ -0,0 +1,74 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/h44z/dntroomloggpro-go/internal"
+)
+
+var (
+	// ErrDeviceNotFound is returned if no matching base station is attached.
+	ErrDeviceNotFound = internal.ErrDeviceNotFound
+	// ErrDisconnected is returned if the connection to the base station is closed or the device was removed.
+	ErrDisconnected = internal.ErrDisconnected
+	// ErrTimeout is returned if the base station did not answer in time.
+	ErrTimeout = internal.ErrTimeout
+	// ErrBadFrame is returned if a response frame is incomplete or malformed.
+	ErrBadFrame = internal.ErrIncompleteFrame
+	// ErrUnexpectedCommand is returned if a response does not belong to the command that was sent. The base station
+	// does not echo the command byte, so a response is matched by its payload size.
+	ErrUnexpectedCommand = internal.ErrUnexpectedResponse
+	// ErrStoreRejected is returned if the base station does not accept the start-store command.
+	ErrStoreRejected = errors.New("store command rejected")
+	// ErrInvalidValue is returned if a value can not be sent to the base station.
+	ErrInvalidValue = errors.New("invalid value")
+	// ErrNotEnabled is returned if an experimental command is used without enabling it in the config.
+	ErrNotEnabled = errors.New("experimental command not enabled")
+)
+
+// requestErrorKinds are the sentinel errors a RequestError is classified as, in order of precedence.
+var requestErrorKinds = []error{
+	ErrStoreRejected, ErrUnexpectedCommand, ErrBadFrame, ErrTimeout, ErrDisconnected, ErrDeviceNotFound,
+}
+
+// RequestError is returned if an exchange with the base station fails. Use errors.Is with one of the Err... values
+// to check the kind of failure.
+type RequestError struct {
+	Command byte  // command that was sent
+	Kind    error // one of the Err... values, nil if the failure is not classified
+	Err     error // underlying error
+}
+
+func newRequestError(command byte, err error) error {
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		return err
+	}
+
+	requestErr = &RequestError{Command: command, Err: err}
+	if errors.Is(err, context.DeadlineExceeded) {
+		requestErr.Kind = ErrTimeout
+	}
+	for _, kind := range requestErrorKinds {
+		if requestErr.Kind == nil && errors.Is(err, kind) {
+			requestErr.Kind = kind
+		}
+	}
+
+	return requestErr
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("command 0x%02x failed: %v", e.Command, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error is classified as the target kind.
+func (e *RequestError) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
@@ -0,0 +1,39 @@
+package pkg_test
+
+import (
+	"fmt"
+
+	"github.com/h44z/dntroomloggpro-go/pkg"
+)
+
+func ExampleNewScriptedTransport() {
+	transport := pkg.NewScriptedTransport()
+	transport.AddResponse(pkg.CommandGetSettings, make([]byte, 7+5*4)) // default settings, the station unit is °C
+	transport.AddResponse(pkg.CommandGetCurrentData, []byte{0x00, 0xd5, 0x30, 0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff,
+		0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff, 0x7f, 0xff, 0xff})
+
+	r := pkg.NewRoomLoggWithTransport(&pkg.RoomLoggConfig{PollingRate: 60}, transport)
+	if err := r.Open(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer r.Close()
+
+	channels, err := r.FetchCurrentData()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, channel := range channels {
+		fmt.Printf("channel %d: %.1f %s, %.0f %%\n", channel.Number, channel.Temperature, channel.Unit.Symbol(),
+			channel.Humidity)
+	}
+	for _, request := range transport.Requests() {
+		fmt.Println(pkg.CommandName(request.Command))
+	}
+
+	// Output:
+	// channel 1: 21.3 °C, 48 %
+	// GetSettings
+	// GetCurrentData
+}
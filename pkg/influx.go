@@ -3,7 +3,6 @@ package pkg
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"github.com/influxdata/influxdb-client-go/v2/api/write"
 
@@ -15,6 +14,17 @@ type InfluxLogger struct {
 	client influxdb2.Client
 }
 
+func init() {
+	RegisterPublisher("influx", PublisherRegistration{
+		Enabled: func(cfg *Config) bool { return cfg.Features.Influx },
+		Config:  func(cfg *Config) any { return cfg.Influx },
+		Queue:   func(cfg *Config) QueueOptions { return cfg.Influx.Queue() },
+		New: func(cfg *Config, _ []*RoomLogg) (Publisher, error) {
+			return NewInfluxLogger(&cfg.Influx), nil
+		},
+	})
+}
+
 func NewInfluxLogger(cfg *InfluxConfig) *InfluxLogger {
 	i := &InfluxLogger{
 		cfg: cfg,
@@ -23,17 +33,33 @@ func NewInfluxLogger(cfg *InfluxConfig) *InfluxLogger {
 	return i
 }
 
-func (l *InfluxLogger) Close() {
+// Start does nothing, the client connects on the first write. An unreachable server is reported by Health.
+func (l *InfluxLogger) Start(_ context.Context) error {
+	return nil
+}
+
+// Health returns an error if the server does not respond to a ping.
+func (l *InfluxLogger) Health(ctx context.Context) error {
+	if ok, err := l.client.Ping(ctx); !ok {
+		return fmt.Errorf("influx server %s is not reachable: %v", l.cfg.URL, err)
+	}
+
+	return nil
+}
+
+func (l *InfluxLogger) Close() error {
 	if l.client != nil {
 		l.client.Close()
 	}
+
+	return nil
 }
 
-func (l *InfluxLogger) logPoints(bucket string, points ...*write.Point) error {
+func (l *InfluxLogger) logPoints(ctx context.Context, bucket string, points ...*write.Point) error {
 	writeAPI := l.client.WriteAPIBlocking("", bucket)
 
 	// Write data
-	err := writeAPI.WritePoint(context.Background(), points...)
+	err := writeAPI.WritePoint(ctx, points...)
 	if err != nil {
 		return fmt.Errorf("failed to write influx points: %w", err)
 	}
@@ -41,31 +67,64 @@ func (l *InfluxLogger) logPoints(bucket string, points ...*write.Point) error {
 	return nil
 }
 
-func (l *InfluxLogger) Publish(settings *SettingsData, channels []*ChannelData, isOnline bool) error {
-	if !isOnline {
+func (l *InfluxLogger) Publish(ctx context.Context, reading *Reading) error {
+	if !reading.Online {
 		return nil // nothing to publish
 	}
 
-	tempUnit := "°C"
-	if settings.Units == UnitFahrenheit {
-		tempUnit = "°F"
+	if err := l.logPoints(ctx, l.cfg.Bucket, l.points(reading)...); err != nil {
+		return err
 	}
 
-	points := make([]*write.Point, 0, len(channels)*2)
-	for _, channel := range channels {
+	return nil
+}
+
+// points returns a temperature and a humidity point per channel. The derived values are extra fields of the point
+// they are computed from: dew point and heat index (temperature unit) of the temperature point, absolute humidity
+// (g/m³) and vapour pressure (hPa) of the humidity point. The unit tag is the unit of the value field.
+func (l *InfluxLogger) points(reading *Reading) []*write.Point {
+	station := reading.Station
+	points := make([]*write.Point, 0, len(reading.Channels)*2)
+	for i, channel := range reading.Channels {
+		derived := reading.Derived[i]
 		points = append(points, influxdb2.NewPoint("temperature", // Measurement
-			map[string]string{"unit": tempUnit, "channel": fmt.Sprintf("%d", channel.Number)}, // Tags
-			map[string]any{"value": channel.Temperature},                                      // Fields
-			time.Now()))
+			l.tags(station, channel, channel.Unit.Symbol()), // Tags
+			map[string]any{ // Fields
+				"value":      channel.Temperature,
+				"dew_point":  derived.DewPoint,
+				"heat_index": derived.HeatIndex,
+			},
+			reading.Time))
 		points = append(points, influxdb2.NewPoint("humidity", // Measurement
-			map[string]string{"unit": "%", "channel": fmt.Sprintf("%d", channel.Number)}, // Tags
-			map[string]any{"value": channel.Humidity},                                    // Fields
-			time.Now()))
+			l.tags(station, channel, "%"), // Tags
+			map[string]any{ // Fields
+				"value":             channel.Humidity,
+				"absolute_humidity": derived.AbsoluteHumidity,
+				"vapour_pressure":   derived.VapourPressure,
+			},
+			reading.Time))
 	}
 
-	if err := l.logPoints(l.cfg.Bucket, points...); err != nil {
-		return err
-	}
+	return points
+}
 
-	return nil
+// tags returns the point tags for a channel, the station tag is only set if multiple stations are used and the
+// metadata tags only if the channel is configured.
+func (l *InfluxLogger) tags(station string, channel *ChannelData, unit string) map[string]string {
+	tags := map[string]string{"unit": unit, "channel": fmt.Sprintf("%d", channel.Number)}
+	if station != "" {
+		tags["station"] = station
+	}
+	if info := channel.Info; info != nil {
+		for key, value := range map[string]string{"name": info.Name, "room": info.Room, "floor": info.Floor} {
+			if value != "" { // influx does not allow empty tag values
+				tags[key] = value
+			}
+		}
+		tags["location"] = "indoor"
+		if info.Outdoor {
+			tags["location"] = "outdoor"
+		}
+	}
+	return tags
 }
@@ -0,0 +1,33 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInfluxLogger_points(t *testing.T) {
+	l := &InfluxLogger{cfg: defaultInfluxConfig()}
+	channel := &ChannelData{Number: 1, Temperature: 30, Humidity: 70}
+	reading := NewReading(time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC), "", nil, []*ChannelData{channel}, true)
+	derived := reading.Derived[0]
+
+	points := l.points(reading)
+	want := map[string]map[string]any{
+		"temperature": {"value": 30.0, "dew_point": derived.DewPoint, "heat_index": derived.HeatIndex},
+		"humidity": {"value": 70.0, "absolute_humidity": derived.AbsoluteHumidity,
+			"vapour_pressure": derived.VapourPressure},
+	}
+	if len(points) != len(want) {
+		t.Fatalf("points() got %d points, want %d", len(points), len(want))
+	}
+	for _, point := range points {
+		fields := map[string]any{}
+		for _, field := range point.FieldList() {
+			fields[field.Key] = field.Value
+		}
+		if !reflect.DeepEqual(fields, want[point.Name()]) {
+			t.Errorf("points() %s fields = %v, want %v", point.Name(), fields, want[point.Name()])
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package pkg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Metrics collects the channel readings and the health of the logger and exposes them in the Prometheus text format.
+type Metrics struct {
+	mux        sync.Mutex
+	stations   map[string]*stationMetrics
+	publishers map[string]*publisherMetrics
+}
+
+type stationMetrics struct {
+	online     bool
+	channels   []*ChannelData // of the last online reading
+	pollCount  uint64
+	pollSum    float64 // seconds
+	pollErrors uint64
+}
+
+type publisherMetrics struct {
+	success uint64
+	failure uint64
+}
+
+// DefaultMetrics collects the metrics of the logger, it is served by the REST server at /metrics.
+var DefaultMetrics = NewMetrics()
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		stations:   make(map[string]*stationMetrics),
+		publishers: make(map[string]*publisherMetrics),
+	}
+}
+
+// ObserveReading updates the online state and the channel gauges of the station. The channels of an offline station
+// are removed, so that no stale values are exposed.
+func (m *Metrics) ObserveReading(reading *Reading) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	station := m.station(reading.Station)
+	station.online = reading.Online
+	station.channels = nil
+	if reading.Online {
+		station.channels = reading.Channels
+	}
+}
+
+// ObservePoll records the duration of a poll of the station, err is the first error of the poll.
+func (m *Metrics) ObservePoll(station string, duration time.Duration, err error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	s := m.station(station)
+	s.pollCount++
+	s.pollSum += duration.Seconds()
+	if err != nil {
+		s.pollErrors++
+	}
+}
+
+// ObservePublish counts a successful or failed publish of the given publisher.
+func (m *Metrics) ObservePublish(publisher string, err error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	p, ok := m.publishers[publisher]
+	if !ok {
+		p = &publisherMetrics{}
+		m.publishers[publisher] = p
+	}
+	if err != nil {
+		p.failure++
+	} else {
+		p.success++
+	}
+}
+
+// station returns the metrics of the given station, the caller must hold the lock.
+func (m *Metrics) station(name string) *stationMetrics {
+	s, ok := m.stations[name]
+	if !ok {
+		s = &stationMetrics{}
+		m.stations[name] = s
+	}
+	return s
+}
+
+// Write writes all metrics in the Prometheus text exposition format.
+func (m *Metrics) Write(w io.Writer) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	stations := make([]string, 0, len(m.stations))
+	for name := range m.stations {
+		stations = append(stations, name)
+	}
+	sort.Strings(stations)
+	publishers := make([]string, 0, len(m.publishers))
+	for name := range m.publishers {
+		publishers = append(publishers, name)
+	}
+	sort.Strings(publishers)
+
+	b := bufio.NewWriter(w)
+
+	writeMetricHeader(b, "roomlogg_temperature_celsius", "gauge", "Temperature of the channel.")
+	for _, name := range stations {
+		for _, ch := range m.stations[name].channels {
+			writeMetric(b, "roomlogg_temperature_celsius", channelLabels(name, ch),
+				ConvertTemperature(ch.Temperature, ch.Unit, UnitCelsius))
+		}
+	}
+	writeMetricHeader(b, "roomlogg_humidity_percent", "gauge", "Relative humidity of the channel.")
+	for _, name := range stations {
+		for _, ch := range m.stations[name].channels {
+			writeMetric(b, "roomlogg_humidity_percent", channelLabels(name, ch), ch.Humidity)
+		}
+	}
+
+	writeMetricHeader(b, "roomlogg_station_online", "gauge", "Whether the station answered the last poll.")
+	for _, name := range stations {
+		online := 0.0
+		if m.stations[name].online {
+			online = 1
+		}
+		writeMetric(b, "roomlogg_station_online", []string{"station", name}, online)
+	}
+	writeMetricHeader(b, "roomlogg_poll_duration_seconds", "summary", "Duration of the station polls.")
+	for _, name := range stations {
+		writeMetric(b, "roomlogg_poll_duration_seconds_sum", []string{"station", name}, m.stations[name].pollSum)
+		writeMetric(b, "roomlogg_poll_duration_seconds_count", []string{"station", name},
+			float64(m.stations[name].pollCount))
+	}
+	writeMetricHeader(b, "roomlogg_poll_errors_total", "counter", "Polls that failed to fetch the station data.")
+	for _, name := range stations {
+		writeMetric(b, "roomlogg_poll_errors_total", []string{"station", name}, float64(m.stations[name].pollErrors))
+	}
+
+	writeMetricHeader(b, "roomlogg_publish_total", "counter", "Published readings by publisher and result.")
+	for _, name := range publishers {
+		writeMetric(b, "roomlogg_publish_total", []string{"publisher", name, "result", "success"},
+			float64(m.publishers[name].success))
+		writeMetric(b, "roomlogg_publish_total", []string{"publisher", name, "result", "failure"},
+			float64(m.publishers[name].failure))
+	}
+
+	return b.Flush()
+}
+
+// ServeHTTP serves the metrics to a Prometheus scrape.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := m.Write(w); err != nil {
+		logrus.Errorf("[REST] Failed to write metrics: %v", err)
+	}
+}
+
+// channelLabels returns the labels of a channel, the name is the configured channel name or empty.
+func channelLabels(station string, ch *ChannelData) []string {
+	name := ""
+	if ch.Info != nil {
+		name = ch.Info.Name
+	}
+
+	return []string{"station", station, "channel", strconv.Itoa(ch.Number), "name", name}
+}
+
+func writeMetricHeader(w io.Writer, name, kind, help string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// writeMetric writes one sample, labels are pairs of label name and value.
+func writeMetric(w io.Writer, name string, labels []string, value float64) {
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], escapeLabelValue(labels[i+1])))
+	}
+
+	_, _ = fmt.Fprintf(w, "%s{%s} %s\n", name, strings.Join(pairs, ","), strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// escapeLabelValue escapes a label value for the text format, which only knows backslash, quote and newline escapes.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
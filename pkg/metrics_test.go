@@ -0,0 +1,77 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics_Write(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveReading(&Reading{Station: "house", Online: true, Channels: []*ChannelData{
+		{Number: 1, Temperature: 21.5, Humidity: 45, Info: &ChannelInfo{Name: `Living "Room"`}},
+		{Number: 2, Temperature: 68, Humidity: 50, Unit: UnitFahrenheit},
+	}})
+	m.ObserveReading(&Reading{Station: "garage", Online: true, Channels: []*ChannelData{{Number: 1}}})
+	m.ObserveReading(&Reading{Station: "garage", Online: false})
+	m.ObservePoll("house", 1500*time.Millisecond, nil)
+	m.ObservePoll("house", 500*time.Millisecond, errors.New("timeout"))
+	m.ObservePublish("mqtt", nil)
+	m.ObservePublish("mqtt", errors.New("broker down"))
+	m.ObservePublish("mqtt", nil)
+
+	buf := &bytes.Buffer{}
+	if err := m.Write(buf); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	want := `# HELP roomlogg_temperature_celsius Temperature of the channel.
+# TYPE roomlogg_temperature_celsius gauge
+roomlogg_temperature_celsius{station="house",channel="1",name="Living \"Room\""} 21.5
+roomlogg_temperature_celsius{station="house",channel="2",name=""} 20
+# HELP roomlogg_humidity_percent Relative humidity of the channel.
+# TYPE roomlogg_humidity_percent gauge
+roomlogg_humidity_percent{station="house",channel="1",name="Living \"Room\""} 45
+roomlogg_humidity_percent{station="house",channel="2",name=""} 50
+# HELP roomlogg_station_online Whether the station answered the last poll.
+# TYPE roomlogg_station_online gauge
+roomlogg_station_online{station="garage"} 0
+roomlogg_station_online{station="house"} 1
+# HELP roomlogg_poll_duration_seconds Duration of the station polls.
+# TYPE roomlogg_poll_duration_seconds summary
+roomlogg_poll_duration_seconds_sum{station="garage"} 0
+roomlogg_poll_duration_seconds_count{station="garage"} 0
+roomlogg_poll_duration_seconds_sum{station="house"} 2
+roomlogg_poll_duration_seconds_count{station="house"} 2
+# HELP roomlogg_poll_errors_total Polls that failed to fetch the station data.
+# TYPE roomlogg_poll_errors_total counter
+roomlogg_poll_errors_total{station="garage"} 0
+roomlogg_poll_errors_total{station="house"} 1
+# HELP roomlogg_publish_total Published readings by publisher and result.
+# TYPE roomlogg_publish_total counter
+roomlogg_publish_total{publisher="mqtt",result="success"} 2
+roomlogg_publish_total{publisher="mqtt",result="failure"} 1
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Write() got = %v, want %v", got, want)
+	}
+}
+
+func TestMetrics_ServeHTTP(t *testing.T) {
+	m := NewMetrics()
+	m.ObservePublish("influx", nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("ServeHTTP() content type got = %v, want %v", got, "text/plain; version=0.0.4")
+	}
+	if want := `roomlogg_publish_total{publisher="influx",result="success"} 1`; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("ServeHTTP() got = %v, want %v", rec.Body.String(), want)
+	}
+}
@@ -1,8 +1,10 @@
 package pkg
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
@@ -16,14 +18,29 @@ type MqttPublisher struct {
 	client mqtt.Client
 }
 
-func NewMqttPublisher(cfg *MqttConfig) (*MqttPublisher, error) {
+func init() {
+	RegisterPublisher("mqtt", PublisherRegistration{
+		Enabled: func(cfg *Config) bool { return cfg.Features.Mqtt },
+		Config:  func(cfg *Config) any { return cfg.Mqtt },
+		Queue:   func(cfg *Config) QueueOptions { return cfg.Mqtt.Queue() },
+		New: func(cfg *Config, _ []*RoomLogg) (Publisher, error) {
+			return NewMqttPublisher(&cfg.Mqtt), nil
+		},
+	})
+}
+
+// NewMqttPublisher creates a publisher for the configured broker, Start connects to the broker.
+func NewMqttPublisher(cfg *MqttConfig) *MqttPublisher {
 	p := &MqttPublisher{
 		cfg: cfg,
 	}
 
-	err := p.Setup()
+	return p
+}
 
-	return p, err
+// Start connects to the broker.
+func (p *MqttPublisher) Start(_ context.Context) error {
+	return p.Setup()
 }
 
 func (p *MqttPublisher) Setup() error {
@@ -45,15 +62,29 @@ func (p *MqttPublisher) Setup() error {
 
 	if token := p.client.Connect(); token.Wait() && token.Error() != nil {
 		logrus.Errorf("[MQTT] Setup of mqtt publisher failed: %v!", token.Error())
-		panic(token.Error())
+		return fmt.Errorf("failed to connect to mqtt broker: %w", token.Error())
 	}
 
 	logrus.Infof("[MQTT] Setup of mqtt publisher completed!")
 	return nil
 }
 
-func (p *MqttPublisher) Close() {
-	p.client.Disconnect(250)
+// Health returns an error if the client is not connected to the broker.
+func (p *MqttPublisher) Health(_ context.Context) error {
+	if p.client == nil || !p.client.IsConnectionOpen() {
+		return fmt.Errorf("not connected to mqtt broker %s:%d", p.cfg.Broker, p.cfg.Port)
+	}
+
+	return nil
+}
+
+// Close waits for pending messages and disconnects from the broker.
+func (p *MqttPublisher) Close() error {
+	if p.client != nil {
+		p.client.Disconnect(250)
+	}
+
+	return nil
 }
 
 func (p *MqttPublisher) onMessageReceived(client mqtt.Client, msg mqtt.Message) {
@@ -69,118 +100,194 @@ func (p *MqttPublisher) onConnectionLostHandler(_ mqtt.Client, err error) {
 	logrus.Warnf("[MQTT] Connection to broker lost: %v!", err)
 }
 
-func (p *MqttPublisher) Publish(settings *SettingsData, channels []*ChannelData, isOnline bool) error {
-	if err := p.publishHomeAssistantConfig(channels); err != nil {
+// mqttSensor is one value of a channel that is published to its own topic.
+type mqttSensor struct {
+	topic       string // topic and discovery object id, e.g. temperature
+	uniqueID    string // suffix of the home assistant unique id, e.g. temp
+	name        string
+	unit        string
+	deviceClass string // home assistant device class, empty if there is none
+	value       func(channel *ChannelData, derived *DerivedData) float64
+}
+
+// mqttSensors returns the published values of a channel with the given temperature unit.
+func mqttSensors(unit Unit) []mqttSensor {
+	tempUnit := unit.Symbol()
+
+	return []mqttSensor{
+		{"temperature", "temp", "Temperature", tempUnit, "temperature",
+			func(ch *ChannelData, _ *DerivedData) float64 { return ch.Temperature }},
+		{"humidity", "humid", "Humidity", "%", "humidity",
+			func(ch *ChannelData, _ *DerivedData) float64 { return ch.Humidity }},
+		{"dew_point", "dew_point", "Dew Point", tempUnit, "temperature",
+			func(_ *ChannelData, d *DerivedData) float64 { return d.DewPoint }},
+		{"heat_index", "heat_index", "Heat Index", tempUnit, "temperature",
+			func(_ *ChannelData, d *DerivedData) float64 { return d.HeatIndex }},
+		{"absolute_humidity", "abs_humid", "Absolute Humidity", "g/m³", "",
+			func(_ *ChannelData, d *DerivedData) float64 { return d.AbsoluteHumidity }},
+		{"vapour_pressure", "vapour_pressure", "Vapour Pressure", "hPa", "pressure",
+			func(_ *ChannelData, d *DerivedData) float64 { return d.VapourPressure }},
+	}
+}
+
+func (p *MqttPublisher) Publish(ctx context.Context, reading *Reading) error {
+	if err := p.publishHomeAssistantConfig(ctx, reading.Station, reading.Channels); err != nil {
 		return fmt.Errorf("failed to publish mqtt config: %w", err)
 	}
 
-	time.Sleep(2 * time.Second) // wait for home assistant to process new topics
+	select { // wait for home assistant to process new topics
+	case <-time.After(2 * time.Second):
+	case <-ctx.Done():
+		return fmt.Errorf("failed to publish mqtt sensors: %w", ctx.Err())
+	}
 
-	if err := p.publishTopics(channels, isOnline); err != nil {
+	if err := p.publishTopics(ctx, reading); err != nil {
 		return fmt.Errorf("failed to publish mqtt sensors: %w", err)
 	}
 
 	return nil
 }
 
-func (p *MqttPublisher) publishHomeAssistantConfig(channels []*ChannelData) error {
-	topicStatus := fmt.Sprintf("homeassistant/binary_sensor/%s/status/config", p.cfg.Topic)
+// Replay sends the values of a spooled reading. The home assistant discovery is left to the next live reading, so that
+// a replay after an outage does not announce the sensors and wait for home assistant again for each reading.
+func (p *MqttPublisher) Replay(ctx context.Context, reading *Reading) error {
+	if err := p.publishTopics(ctx, reading); err != nil {
+		return fmt.Errorf("failed to replay mqtt sensors: %w", err)
+	}
+
+	return nil
+}
+
+// publish sends the payload and waits until it is delivered or the context is done.
+func (p *MqttPublisher) publish(ctx context.Context, topic string, payload string) error {
+	token := p.client.Publish(topic, 0, false, payload)
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *MqttPublisher) publishHomeAssistantConfig(ctx context.Context, station string, channels []*ChannelData) error {
+	deviceID := p.deviceID(station)
+	stationTopic := p.stationTopic(station)
+
+	topicStatus := fmt.Sprintf("homeassistant/binary_sensor/%s/status/config", deviceID)
 	availabilityConfig := map[string]any{
 		"name":               "Status",
-		"state_topic":        fmt.Sprintf("roomlogg/%s/status", p.cfg.Topic),
-		"availability_topic": fmt.Sprintf("roomlogg/%s/status", p.cfg.Topic),
+		"state_topic":        stationTopic + "/status",
+		"availability_topic": stationTopic + "/status",
 		"device_class":       "connectivity",
 		"payload_on":         "online",
 		"payload_off":        "offline",
 		"expire_after":       "240",
-		"unique_id":          fmt.Sprintf("roomlogg_%s_status", p.cfg.Topic),
-		"device": map[string]any{
-			"identifiers":  p.cfg.Topic,
-			"name":         p.cfg.Topic,
-			"manufacturer": "DNT",
-			"model":        "DNT RoomLogg PRO",
-		},
+		"unique_id":          fmt.Sprintf("roomlogg_%s_status", deviceID),
+		"device":             p.deviceConfig(deviceID),
 	}
 
 	payload, _ := json.Marshal(availabilityConfig)
-	token := p.client.Publish(topicStatus, 0, false, string(payload))
-	token.Wait()
+	if err := p.publish(ctx, topicStatus, string(payload)); err != nil {
+		return err
+	}
 
 	for _, ch := range channels {
-		topicTemperature := fmt.Sprintf("homeassistant/sensor/%s/temperature_%d/config", p.cfg.Topic, ch.Number)
-		temperatureConfig := map[string]any{
-			"name":                fmt.Sprintf("Temperature Channel %d", ch.Number),
-			"state_topic":         fmt.Sprintf("roomlogg/%s/temperature/%d", p.cfg.Topic, ch.Number),
-			"availability_topic":  fmt.Sprintf("roomlogg/%s/status", p.cfg.Topic),
-			"unit_of_measurement": "°C",
-			"device_class":        "temperature",
-			"state_class":         "measurement",
-			"value_template":      "{{ value_json.value | float }}",
-			"unique_id":           fmt.Sprintf("roomlogg_%s_temp_%d", p.cfg.Topic, ch.Number),
-			"device": map[string]any{
-				"identifiers":  p.cfg.Topic,
-				"name":         p.cfg.Topic,
-				"manufacturer": "DNT",
-				"model":        "DNT RoomLogg PRO",
-			},
+		for _, sensor := range mqttSensors(ch.Unit) {
+			topic := fmt.Sprintf("homeassistant/sensor/%s/%s_%d/config", deviceID, sensor.topic, ch.Number)
+			name := fmt.Sprintf("%s Channel %d", sensor.name, ch.Number)
+			if ch.Info != nil && ch.Info.Name != "" {
+				name = fmt.Sprintf("%s %s", ch.Info.Name, sensor.name)
+			}
+			config := map[string]any{
+				"name":                name,
+				"state_topic":         fmt.Sprintf("%s/%s/%s", stationTopic, sensor.topic, channelTopic(ch)),
+				"availability_topic":  stationTopic + "/status",
+				"unit_of_measurement": sensor.unit,
+				"state_class":         "measurement",
+				"value_template":      "{{ value_json.value | float }}",
+				"unique_id":           fmt.Sprintf("roomlogg_%s_%s_%d", deviceID, sensor.uniqueID, ch.Number),
+				"device":              p.deviceConfig(deviceID),
+			}
+			if sensor.deviceClass != "" {
+				config["device_class"] = sensor.deviceClass
+			}
+			payload, _ = json.Marshal(config)
+			if err := p.publish(ctx, topic, string(payload)); err != nil {
+				return err
+			}
 		}
-		payload, _ = json.Marshal(temperatureConfig)
-		token = p.client.Publish(topicTemperature, 0, false, string(payload))
-		token.Wait()
-
-		topicHumidity := fmt.Sprintf("homeassistant/sensor/%s/humidity_%d/config", p.cfg.Topic, ch.Number)
-		humidityConfig := map[string]any{
-			"name":                fmt.Sprintf("Humidity Channel %d", ch.Number),
-			"state_topic":         fmt.Sprintf("roomlogg/%s/humidity/%d", p.cfg.Topic, ch.Number),
-			"availability_topic":  fmt.Sprintf("roomlogg/%s/status", p.cfg.Topic),
-			"unit_of_measurement": "%",
-			"device_class":        "humidity",
-			"state_class":         "measurement",
-			"value_template":      "{{ value_json.value | float }}",
-			"unique_id":           fmt.Sprintf("roomlogg_%s_humid_%d", p.cfg.Topic, ch.Number),
-			"device": map[string]any{
-				"identifiers":  p.cfg.Topic,
-				"name":         p.cfg.Topic,
-				"manufacturer": "DNT",
-				"model":        "DNT RoomLogg PRO",
-			},
-		}
-		payload, _ = json.Marshal(humidityConfig)
-		token = p.client.Publish(topicHumidity, 0, false, string(payload))
-		token.Wait()
 	}
 	return nil
 }
 
-func (p *MqttPublisher) publishTopics(channels []*ChannelData, isOnline bool) error {
-	topicStatus := fmt.Sprintf("roomlogg/%s/status", p.cfg.Topic)
+func (p *MqttPublisher) publishTopics(ctx context.Context, reading *Reading) error {
+	stationTopic := p.stationTopic(reading.Station)
+
+	topicStatus := stationTopic + "/status"
 	status := "offline"
-	if isOnline {
+	if reading.Online {
 		status = "online"
 	}
-	token := p.client.Publish(topicStatus, 0, false, status)
-	token.Wait()
+	if err := p.publish(ctx, topicStatus, status); err != nil {
+		return err
+	}
 
-	for _, ch := range channels {
-		topicTemperature := fmt.Sprintf("roomlogg/%s/temperature/%d", p.cfg.Topic, ch.Number)
-		temperatureValue := map[string]any{
-			"value":   ch.Temperature,
-			"unit":    "°C",
-			"channel": ch.Number,
-		}
-		payload, _ := json.Marshal(temperatureValue)
-		token = p.client.Publish(topicTemperature, 0, false, string(payload))
-		token.Wait()
-
-		topicHumidity := fmt.Sprintf("roomlogg/%s/humidity/%d", p.cfg.Topic, ch.Number)
-		humidityValue := map[string]any{
-			"value":   ch.Humidity,
-			"unit":    "%",
-			"channel": ch.Number,
+	for i, ch := range reading.Channels {
+		for _, sensor := range mqttSensors(ch.Unit) {
+			topic := fmt.Sprintf("%s/%s/%s", stationTopic, sensor.topic, channelTopic(ch))
+			value := map[string]any{
+				"value":   sensor.value(ch, reading.Derived[i]),
+				"unit":    sensor.unit,
+				"channel": ch.Number,
+				"time":    reading.Time.Format(time.RFC3339),
+			}
+			if ch.Info != nil {
+				value["name"] = ch.Info.Name
+				value["room"] = ch.Info.Room
+				value["floor"] = ch.Info.Floor
+				value["outdoor"] = ch.Info.Outdoor
+			}
+			payload, _ := json.Marshal(value)
+			if err := p.publish(ctx, topic, string(payload)); err != nil {
+				return err
+			}
 		}
-		payload, _ = json.Marshal(humidityValue)
-		token = p.client.Publish(topicHumidity, 0, false, string(payload))
-		token.Wait()
 	}
 	return nil
 }
+
+// channelTopic returns the topic level of a channel, the slug of its name or the channel number if it has no name.
+func channelTopic(ch *ChannelData) string {
+	if ch.Info != nil {
+		if slug := ch.Info.Slug(); slug != "" {
+			return slug
+		}
+	}
+	return strconv.Itoa(ch.Number)
+}
+
+// deviceConfig returns the home assistant device of the given station.
+func (p *MqttPublisher) deviceConfig(deviceID string) map[string]any {
+	return map[string]any{
+		"identifiers":  deviceID,
+		"name":         deviceID,
+		"manufacturer": "DNT",
+		"model":        "DNT RoomLogg PRO",
+	}
+}
+
+// stationTopic returns the topic prefix for the given station. The station is omitted if only one station is used.
+func (p *MqttPublisher) stationTopic(station string) string {
+	if station == "" {
+		return fmt.Sprintf("roomlogg/%s", p.cfg.Topic)
+	}
+	return fmt.Sprintf("roomlogg/%s/%s", p.cfg.Topic, station)
+}
+
+// deviceID returns the home assistant device identifier for the given station.
+func (p *MqttPublisher) deviceID(station string) string {
+	if station == "" {
+		return p.cfg.Topic
+	}
+	return fmt.Sprintf("%s_%s", p.cfg.Topic, station)
+}
@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Reading is the result of one poll of a station, as it is handed to the publishers.
+type Reading struct {
+	Time     time.Time
+	Station  string // empty if only one station is used
+	Online   bool
+	Settings *SettingsData // nil if the settings could not be fetched
+	Channels []*ChannelData
+	Derived  []*DerivedData // derived values of each channel, in the order of Channels
+}
+
+// NewReading creates a reading of the given station and computes the derived values of the channels.
+func NewReading(t time.Time, station string, settings *SettingsData, channels []*ChannelData, online bool) *Reading {
+	derived := make([]*DerivedData, len(channels))
+	for i, channel := range channels {
+		derived[i] = NewDerivedData(channel)
+	}
+
+	return &Reading{
+		Time:     t,
+		Station:  station,
+		Online:   online,
+		Settings: settings,
+		Channels: channels,
+		Derived:  derived,
+	}
+}
+
+// Publisher sends readings to a sink.
+type Publisher interface {
+	// Start connects to the sink, it is called once before the first reading is published.
+	Start(ctx context.Context) error
+	// Publish sends the reading, it aborts once the context is done.
+	Publish(ctx context.Context, reading *Reading) error
+	// Health returns an error if the sink can not be reached.
+	Health(ctx context.Context) error
+	// Close flushes pending writes and disconnects from the sink.
+	Close() error
+}
+
+// Replayer is implemented by publishers that publish spooled readings differently than live readings, e.g. without
+// announcing the sensors again. Publishers without Replay get the spooled readings through Publish.
+type Replayer interface {
+	// Replay sends a spooled reading, it aborts once the context is done.
+	Replay(ctx context.Context, reading *Reading) error
+}
+
+// PublisherRegistration describes how a publisher is built from the configuration.
+type PublisherRegistration struct {
+	// Enabled reports whether the publisher is enabled in the config.
+	Enabled func(cfg *Config) bool
+	// Config returns the config section of the publisher, the publisher is rebuilt on reloads if it changed.
+	Config func(cfg *Config) any
+	// Queue returns the queue options of the publisher, DefaultQueueOptions are used if it is nil.
+	Queue func(cfg *Config) QueueOptions
+	// New creates the publisher, the stations may be used for direct requests.
+	New func(cfg *Config, stations []*RoomLogg) (Publisher, error)
+}
+
+var (
+	publisherRegistry    = map[string]PublisherRegistration{}
+	publisherRegistryMux sync.RWMutex
+)
+
+// RegisterPublisher makes a publisher available by name. It panics if the name is already taken or the registration
+// is incomplete, it is meant to be called from init functions.
+func RegisterPublisher(name string, registration PublisherRegistration) {
+	publisherRegistryMux.Lock()
+	defer publisherRegistryMux.Unlock()
+
+	if registration.Enabled == nil || registration.Config == nil || registration.New == nil {
+		panic(fmt.Sprintf("publisher %s: incomplete registration", name))
+	}
+	if _, ok := publisherRegistry[name]; ok {
+		panic(fmt.Sprintf("publisher %s: registered twice", name))
+	}
+	publisherRegistry[name] = registration
+}
+
+// RegisteredPublishers returns the names of all registered publishers in sorted order.
+func RegisteredPublishers() []string {
+	publisherRegistryMux.RLock()
+	defer publisherRegistryMux.RUnlock()
+
+	names := make([]string, 0, len(publisherRegistry))
+	for name := range publisherRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// LookupPublisher returns the registration of the publisher with the given name.
+func LookupPublisher(name string) (PublisherRegistration, bool) {
+	publisherRegistryMux.RLock()
+	defer publisherRegistryMux.RUnlock()
+
+	registration, ok := publisherRegistry[name]
+	return registration, ok
+}
@@ -0,0 +1,54 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewReading(t *testing.T) {
+	now := time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC)
+	channels := []*ChannelData{{Number: 1, Temperature: 20, Humidity: 50, Unit: UnitCelsius}}
+
+	got := NewReading(now, "garage", nil, channels, true)
+	want := &Reading{
+		Time:     now,
+		Station:  "garage",
+		Online:   true,
+		Channels: channels,
+		Derived:  []*DerivedData{NewDerivedData(channels[0])},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NewReading() got = %v, want %v", got, want)
+	}
+}
+
+func TestRegisteredPublishers(t *testing.T) {
+	want := []string{"influx", "mqtt", "rest"}
+	if got := RegisteredPublishers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("RegisteredPublishers() got = %v, want %v", got, want)
+	}
+
+	cfg := defaultConfig()
+	cfg.Features.Mqtt = false
+	for name, wantEnabled := range map[string]bool{"influx": true, "mqtt": false, "rest": true} {
+		registration, ok := LookupPublisher(name)
+		if !ok {
+			t.Fatalf("LookupPublisher(%q) not found", name)
+		}
+		if got := registration.Enabled(cfg); got != wantEnabled {
+			t.Errorf("LookupPublisher(%q).Enabled() got = %v, want %v", name, got, wantEnabled)
+		}
+	}
+}
+
+func TestRegisterPublisher_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RegisterPublisher() did not panic")
+		}
+	}()
+
+	registration, _ := LookupPublisher("rest")
+	RegisterPublisher("rest", registration)
+}
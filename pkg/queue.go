@@ -0,0 +1,327 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/h44z/dntroomloggpro-go/internal"
+	"github.com/sirupsen/logrus"
+)
+
+// OverflowPolicy decides which reading is dropped if the queue of a publisher is full.
+type OverflowPolicy string
+
+const (
+	OverflowDropOldest OverflowPolicy = "drop-oldest" // keeps the latest readings
+	OverflowDropNewest OverflowPolicy = "drop-newest" // keeps the readings that are already queued
+)
+
+// Validate checks that the policy is known.
+func (p OverflowPolicy) Validate() error {
+	switch p {
+	case OverflowDropOldest, OverflowDropNewest:
+		return nil
+	default:
+		return fmt.Errorf("%w: overflow policy %q, want %s or %s", ErrInvalidValue, p, OverflowDropOldest,
+			OverflowDropNewest)
+	}
+}
+
+// QueueOptions bound the queue of a publisher.
+type QueueOptions struct {
+	Size     int           // readings that wait to be published
+	Timeout  time.Duration // per publish
+	Overflow OverflowPolicy
+	Spool    SpoolOptions // keeps the readings on disk while the publisher fails
+}
+
+const (
+	spoolRetryMin = 1 * time.Second
+	spoolRetryMax = 1 * time.Minute
+)
+
+// DefaultQueueOptions returns the queue options of publishers that do not configure their queue.
+func DefaultQueueOptions() QueueOptions {
+	return QueueOptions{Size: 10, Timeout: 30 * time.Second, Overflow: OverflowDropOldest}
+}
+
+// newQueueOptions converts the queue settings of a publisher config.
+func newQueueOptions(size, timeout int, overflow OverflowPolicy) QueueOptions {
+	return QueueOptions{Size: size, Timeout: time.Duration(timeout) * time.Second, Overflow: overflow}
+}
+
+// newSpoolOptions converts the spool settings of a publisher config.
+func newSpoolOptions(dir string, maxSize, maxAge int) SpoolOptions {
+	return SpoolOptions{Dir: dir, MaxSize: int64(maxSize) << 20, MaxAge: time.Duration(maxAge) * time.Second}
+}
+
+// validateSpool checks the spool settings of a publisher config.
+func validateSpool(name string, maxSize, maxAge int) error {
+	if maxSize < 1 {
+		return fmt.Errorf("%w: %s spool max size %d must be positive", ErrInvalidValue, name, maxSize)
+	}
+	if maxAge < 1 {
+		return fmt.Errorf("%w: %s spool max age %d must be positive", ErrInvalidValue, name, maxAge)
+	}
+
+	return nil
+}
+
+// validateQueue checks the queue settings of a publisher config.
+func validateQueue(name string, size, timeout int, overflow OverflowPolicy) error {
+	if size < 1 {
+		return fmt.Errorf("%w: %s queue size %d must be positive", ErrInvalidValue, name, size)
+	}
+	if timeout < 1 {
+		return fmt.Errorf("%w: %s timeout %d must be positive", ErrInvalidValue, name, timeout)
+	}
+	if err := overflow.Validate(); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	return nil
+}
+
+// PublisherQueue hands readings to a publisher through a bounded queue, so that a slow publisher never blocks the
+// caller. One worker publishes the readings in order, each publish is aborted after the timeout.
+//
+// If a spool is configured, readings that fail to publish are stored on disk and replayed in order once the publisher
+// recovers. New readings are spooled as well until the replay caught up, so that the order is kept.
+type PublisherQueue struct {
+	name      string
+	publisher Publisher
+	opts      QueueOptions
+	spool     *Spool // nil if disabled
+
+	queue  chan *Reading
+	closed bool
+	mux    sync.Mutex // serializes enqueueing, so that dropping the oldest reading and closing do not race
+
+	cancel context.CancelFunc // aborts the running publish once Close gives up
+	done   chan struct{}      // closed once the worker returned
+}
+
+// NewPublisherQueue starts the worker of the publisher. The publisher must be started already. The spool of the
+// publisher is kept in a subdirectory named after the publisher, readings of previous runs are replayed.
+func NewPublisherQueue(name string, publisher Publisher, opts QueueOptions) (*PublisherQueue, error) {
+	var spool *Spool
+	if opts.Spool.Dir != "" {
+		spoolOpts := opts.Spool
+		spoolOpts.Dir = filepath.Join(spoolOpts.Dir, name)
+		var err error
+		if spool, err = OpenSpool(spoolOpts); err != nil {
+			return nil, fmt.Errorf("failed to open spool of publisher %s: %w", name, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &PublisherQueue{
+		name:      name,
+		publisher: publisher,
+		opts:      opts,
+		spool:     spool,
+		queue:     make(chan *Reading, opts.Size),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	go q.work(ctx)
+
+	return q, nil
+}
+
+// Enqueue queues the reading without blocking. If the queue is full, a reading is dropped according to the overflow
+// policy and false is returned.
+func (q *PublisherQueue) Enqueue(reading *Reading) bool {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	if q.closed {
+		return false
+	}
+
+	select {
+	case q.queue <- reading:
+		return true
+	default:
+	}
+
+	if q.opts.Overflow == OverflowDropNewest {
+		logrus.Warnf("Queue of publisher %s is full, dropped the reading of %s", q.name, reading.Time.Format(time.RFC3339))
+		return false
+	}
+
+	select {
+	case dropped := <-q.queue:
+		logrus.Warnf("Queue of publisher %s is full, dropped the reading of %s", q.name, dropped.Time.Format(time.RFC3339))
+	default: // the worker took one in between
+	}
+	q.queue <- reading // the lock guarantees that there is room now
+
+	return false
+}
+
+// Len returns the number of queued readings.
+func (q *PublisherQueue) Len() int {
+	return len(q.queue)
+}
+
+// Spooled returns the number of readings that wait in the spool.
+func (q *PublisherQueue) Spooled() int {
+	if q.spool == nil {
+		return 0
+	}
+
+	return q.spool.Len()
+}
+
+// Close stops accepting readings and waits until the queued readings are published. Spooled readings, including the
+// queued ones that had to wait behind them, are replayed in order until the publisher fails. Once the context is done,
+// the running publish is aborted and the remaining readings are spooled, or dropped if there is no spool.
+func (q *PublisherQueue) Close(ctx context.Context) error {
+	q.mux.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.queue)
+	}
+	q.mux.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-q.done
+		return fmt.Errorf("failed to flush queue of publisher %s: %w", q.name, ctx.Err())
+	}
+}
+
+func (q *PublisherQueue) work(ctx context.Context) {
+	defer close(q.done)
+	defer q.cancel()
+
+	backoff := internal.Backoff{Min: spoolRetryMin, Max: spoolRetryMax}
+	var retry <-chan time.Time // set while the spool waits for a replay
+	if q.Spooled() > 0 {
+		retry = time.After(0) // readings of a previous run
+	}
+
+	for {
+		select {
+		case reading, ok := <-q.queue:
+			if !ok {
+				if q.Spooled() > 0 && ctx.Err() == nil {
+					q.replay(ctx) // e.g. the offline state of the shutdown, the rest is replayed by the next run
+				}
+				return
+			}
+			if ctx.Err() != nil || q.Spooled() > 0 {
+				q.keep(reading) // readings must not overtake the spooled ones
+				continue
+			}
+			if err := q.publish(ctx, reading, false); err != nil {
+				q.keep(reading)
+				if q.spool != nil && retry == nil {
+					retry = time.After(backoff.Next())
+				}
+			}
+		case <-retry:
+			if q.replay(ctx) {
+				backoff.Reset()
+				retry = nil
+			} else {
+				retry = time.After(backoff.Next())
+			}
+		}
+	}
+}
+
+// publish publishes the reading, the publish is aborted after the timeout. Spooled readings are replayed through
+// Replay if the publisher implements Replayer.
+func (q *PublisherQueue) publish(ctx context.Context, reading *Reading, replay bool) error {
+	publishCtx, cancel := context.WithTimeout(ctx, q.opts.Timeout)
+	defer cancel()
+
+	var err error
+	if replayer, ok := q.publisher.(Replayer); ok && replay {
+		err = replayer.Replay(publishCtx, reading)
+	} else {
+		err = q.publisher.Publish(publishCtx, reading)
+	}
+	DefaultMetrics.ObservePublish(q.name, err)
+	if err != nil {
+		logrus.Errorf("Failed to publish to %s: %v", q.name, err)
+		return err
+	}
+	logrus.Debugf("Published to %s", q.name)
+
+	return nil
+}
+
+// keep stores the reading in the spool, without a spool the reading is dropped.
+func (q *PublisherQueue) keep(reading *Reading) {
+	if q.spool == nil {
+		return
+	}
+
+	if err := q.spool.Push(reading); err != nil {
+		logrus.Errorf("Failed to spool the reading of %s for %s: %v", reading.Time.Format(time.RFC3339), q.name, err)
+	}
+}
+
+// replay publishes the spooled readings in order and returns false if the publisher still fails or the context is
+// done. Queued readings are moved to the spool in between, so that a long replay does not overflow the queue. Once the
+// queue is closed, the spool is replayed until it is empty.
+func (q *PublisherQueue) replay(ctx context.Context) bool {
+	replayed := 0
+	for {
+		q.spoolQueued()
+		if ctx.Err() != nil {
+			return false // the next run replays the rest
+		}
+
+		reading, err := q.spool.Peek()
+		if err != nil {
+			logrus.Errorf("Failed to replay spool of %s: %v", q.name, err)
+			if err := q.spool.Pop(); err != nil { // skip the broken reading
+				return false
+			}
+			continue
+		}
+		if reading == nil {
+			break
+		}
+
+		if err := q.publish(ctx, reading, true); err != nil {
+			return false
+		}
+		if err := q.spool.Pop(); err != nil {
+			logrus.Errorf("Failed to replay spool of %s: %v", q.name, err)
+			return false
+		}
+		replayed++
+	}
+
+	if replayed > 0 {
+		logrus.Infof("Replayed %d spooled readings to %s", replayed, q.name)
+	}
+
+	return true
+}
+
+// spoolQueued moves the queued readings to the spool without blocking.
+func (q *PublisherQueue) spoolQueued() {
+	for {
+		select {
+		case reading, ok := <-q.queue:
+			if !ok {
+				return
+			}
+			q.keep(reading)
+		default:
+			return
+		}
+	}
+}
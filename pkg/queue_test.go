@@ -0,0 +1,354 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePublisher records the stations of the published readings, each publish waits until release is closed.
+type fakePublisher struct {
+	started chan struct{} // receives each publish before it waits
+	release chan struct{}
+
+	mux       sync.Mutex
+	fail      error // returned by each publish while set
+	published []string
+	errs      []error
+}
+
+func newFakePublisher() *fakePublisher {
+	return &fakePublisher{started: make(chan struct{}, 100), release: make(chan struct{})}
+}
+
+func (p *fakePublisher) Start(_ context.Context) error  { return nil }
+func (p *fakePublisher) Health(_ context.Context) error { return nil }
+func (p *fakePublisher) Close() error                   { return nil }
+
+func (p *fakePublisher) Publish(ctx context.Context, reading *Reading) error {
+	p.started <- struct{}{}
+
+	var err error
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if err == nil {
+		err = p.fail
+	}
+	if err == nil {
+		p.published = append(p.published, reading.Station)
+	}
+	p.errs = append(p.errs, err)
+
+	return err
+}
+
+func (p *fakePublisher) setFail(err error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.fail = err
+}
+
+func (p *fakePublisher) result() ([]string, []error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	return p.published, p.errs
+}
+
+func TestPublisherQueue_Overflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		overflow OverflowPolicy
+		want     []string
+	}{
+		{"DropOldest", OverflowDropOldest, []string{"1", "3", "4"}},
+		{"DropNewest", OverflowDropNewest, []string{"1", "2", "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newFakePublisher()
+			q, err := NewPublisherQueue("fake", p, QueueOptions{Size: 2, Timeout: time.Minute, Overflow: tt.overflow})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			q.Enqueue(&Reading{Station: "1"})
+			<-p.started // the worker is busy with the first reading
+			gotQueued := []bool{q.Enqueue(&Reading{Station: "2"}), q.Enqueue(&Reading{Station: "3"}),
+				q.Enqueue(&Reading{Station: "4"})}
+			if wantQueued := []bool{true, true, false}; !reflect.DeepEqual(gotQueued, wantQueued) {
+				t.Errorf("Enqueue() got = %v, want %v", gotQueued, wantQueued)
+			}
+
+			close(p.release)
+			if err := q.Close(context.Background()); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if got, _ := p.result(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Publish() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublisherQueue_Timeout(t *testing.T) {
+	p := newFakePublisher()
+	q, err := NewPublisherQueue("fake", p, QueueOptions{Size: 1, Timeout: 10 * time.Millisecond, Overflow: OverflowDropOldest})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.Enqueue(&Reading{Station: "1"})
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, errs := p.result(); len(errs) != 1 || !errors.Is(errs[0], context.DeadlineExceeded) {
+		t.Errorf("Publish() errors = %v, want %v", errs, context.DeadlineExceeded)
+	}
+}
+
+func TestPublisherQueue_CloseTimeout(t *testing.T) {
+	p := newFakePublisher()
+	q, err := NewPublisherQueue("fake", p, QueueOptions{Size: 2, Timeout: time.Minute, Overflow: OverflowDropOldest})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.Enqueue(&Reading{Station: "1"})
+	q.Enqueue(&Reading{Station: "2"})
+	<-p.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if _, errs := p.result(); len(errs) != 1 || !errors.Is(errs[0], context.Canceled) {
+		t.Errorf("Publish() errors = %v, want only %v", errs, context.Canceled)
+	}
+	if q.Enqueue(&Reading{Station: "3"}) {
+		t.Errorf("Enqueue() after Close() got = true, want false")
+	}
+}
+
+func TestPublisherQueue_NeverBlocks(t *testing.T) {
+	p := newFakePublisher()
+	q, err := NewPublisherQueue("fake", p, QueueOptions{Size: 1, Timeout: time.Minute, Overflow: OverflowDropOldest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		close(p.release)
+		_ = q.Close(context.Background())
+	}()
+
+	q.Enqueue(&Reading{})
+	<-p.started // the worker hangs from now on
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			q.Enqueue(&Reading{})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Enqueue() blocked on a hung publisher")
+	}
+	if got := q.Len(); got != 1 {
+		t.Errorf("Len() got = %v, want %v", got, 1)
+	}
+}
+
+// waitFor polls the condition until it holds or fails the test after a few seconds.
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); !condition(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+	}
+}
+
+func TestPublisherQueue_Spool(t *testing.T) {
+	opts := QueueOptions{Size: 10, Timeout: time.Minute, Overflow: OverflowDropOldest,
+		Spool: SpoolOptions{Dir: t.TempDir(), MaxSize: 1 << 20, MaxAge: time.Hour}}
+	p := newFakePublisher()
+	close(p.release)
+	p.setFail(errors.New("sink down"))
+	q, err := NewPublisherQueue("fake", p, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.Enqueue(&Reading{Time: time.Now(), Station: "1"})
+	q.Enqueue(&Reading{Time: time.Now(), Station: "2"})
+	waitFor(t, "spooled readings", func() bool { return q.Spooled() == 2 })
+
+	p.setFail(nil) // the next retry replays the spool
+	q.Enqueue(&Reading{Time: time.Now(), Station: "3"})
+	waitFor(t, "replay", func() bool { got, _ := p.result(); return len(got) == 3 })
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	want := []string{"1", "2", "3"}
+	if got, _ := p.result(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Publish() got = %v, want %v", got, want)
+	}
+	if got := q.Spooled(); got != 0 {
+		t.Errorf("Spooled() got = %v, want %v", got, 0)
+	}
+}
+
+func TestPublisherQueue_SpoolRestart(t *testing.T) {
+	opts := QueueOptions{Size: 10, Timeout: time.Minute, Overflow: OverflowDropOldest,
+		Spool: SpoolOptions{Dir: t.TempDir(), MaxSize: 1 << 20, MaxAge: time.Hour}}
+
+	failing := newFakePublisher()
+	close(failing.release)
+	failing.setFail(errors.New("sink down"))
+	q, err := NewPublisherQueue("fake", failing, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Enqueue(&Reading{Time: time.Now(), Station: "1"})
+	q.Enqueue(&Reading{Time: time.Now(), Station: "2"})
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got := q.Spooled(); got != 2 {
+		t.Fatalf("Spooled() got = %v, want %v", got, 2)
+	}
+
+	p := newFakePublisher()
+	close(p.release)
+	q, err = NewPublisherQueue("fake", p, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "replay", func() bool { return q.Spooled() == 0 })
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	want := []string{"1", "2"}
+	if got, _ := p.result(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Publish() got = %v, want %v", got, want)
+	}
+}
+
+func TestPublisherQueue_CloseReplaysSpool(t *testing.T) {
+	opts := QueueOptions{Size: 10, Timeout: time.Minute, Overflow: OverflowDropOldest,
+		Spool: SpoolOptions{Dir: t.TempDir(), MaxSize: 1 << 20, MaxAge: time.Hour}}
+	p := newFakePublisher()
+	close(p.release)
+	p.setFail(errors.New("sink down"))
+	q, err := NewPublisherQueue("fake", p, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.Enqueue(&Reading{Time: time.Now(), Station: "1"})
+	q.Enqueue(&Reading{Time: time.Now(), Station: "2"})
+	waitFor(t, "spooled readings", func() bool { return q.Spooled() == 2 })
+
+	p.setFail(nil)                                                           // recovers before the retry is due
+	q.Enqueue(&Reading{Time: time.Now(), Station: "offline", Online: false}) // waits behind the spool
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	want := []string{"1", "2", "offline"}
+	if got, _ := p.result(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Publish() got = %v, want %v", got, want)
+	}
+	if got := q.Spooled(); got != 0 {
+		t.Errorf("Spooled() got = %v, want %v", got, 0)
+	}
+}
+
+func TestPublisherQueue_CloseSpoolTimeout(t *testing.T) {
+	opts := QueueOptions{Size: 10, Timeout: time.Minute, Overflow: OverflowDropOldest,
+		Spool: SpoolOptions{Dir: t.TempDir(), MaxSize: 1 << 20, MaxAge: time.Hour}}
+	p := newFakePublisher() // never released, each publish hangs until it is aborted
+	p.setFail(errors.New("sink down"))
+	q, err := NewPublisherQueue("fake", p, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.Enqueue(&Reading{Time: time.Now(), Station: "1"})
+	<-p.started
+	q.Enqueue(&Reading{Time: time.Now(), Station: "offline"})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := q.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if got := q.Spooled(); got != 2 { // kept for the next run
+		t.Errorf("Spooled() got = %v, want %v", got, 2)
+	}
+}
+
+// replayingPublisher records replayed readings separately from published ones.
+type replayingPublisher struct {
+	*fakePublisher
+	replayed []string
+}
+
+func (p *replayingPublisher) Replay(_ context.Context, reading *Reading) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.replayed = append(p.replayed, reading.Station)
+	return nil
+}
+
+func TestPublisherQueue_SpoolReplayer(t *testing.T) {
+	opts := QueueOptions{Size: 10, Timeout: time.Minute, Overflow: OverflowDropOldest,
+		Spool: SpoolOptions{Dir: t.TempDir(), MaxSize: 1 << 20, MaxAge: time.Hour}}
+	p := &replayingPublisher{fakePublisher: newFakePublisher()}
+	close(p.release)
+	p.setFail(errors.New("sink down"))
+	q, err := NewPublisherQueue("fake", p, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.Enqueue(&Reading{Time: time.Now(), Station: "1"})
+	waitFor(t, "spooled readings", func() bool { return q.Spooled() == 1 })
+	p.setFail(nil)
+	q.Enqueue(&Reading{Time: time.Now(), Station: "2"})
+	waitFor(t, "replay", func() bool { return q.Spooled() == 0 })
+	q.Enqueue(&Reading{Time: time.Now(), Station: "3"})
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if want := []string{"1", "2"}; !reflect.DeepEqual(p.replayed, want) {
+		t.Errorf("Replay() got = %v, want %v", p.replayed, want)
+	}
+	if want := []string{"3"}; !reflect.DeepEqual(p.published, want) {
+		t.Errorf("Publish() got = %v, want %v", p.published, want)
+	}
+}
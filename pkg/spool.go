@@ -0,0 +1,196 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SpoolOptions limit the readings that are kept on disk while a publisher fails.
+type SpoolOptions struct {
+	Dir     string // empty disables the spool
+	MaxSize int64  // bytes, the oldest readings are dropped first
+	MaxAge  time.Duration
+}
+
+// Spool is a durable FIFO of readings. Each reading is stored in its own file, named by a sequence number, so the
+// readings survive restarts of the logger. Once the spool exceeds its size, the oldest readings are dropped. Readings
+// that are older than the maximum age are dropped as well.
+type Spool struct {
+	opts    SpoolOptions
+	entries []spoolEntry // oldest first
+	size    int64
+	next    uint64 // sequence number of the next reading
+	mux     sync.Mutex
+}
+
+type spoolEntry struct {
+	name string
+	size int64
+	time time.Time // time of the reading
+}
+
+const spoolFileExt = ".json"
+
+// OpenSpool opens the spool in the given directory and loads the readings of previous runs. The directory is created
+// if it does not exist. Files that can not be decoded are removed.
+func OpenSpool(opts SpoolOptions) (*Spool, error) {
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	files, err := os.ReadDir(opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), spoolFileExt) {
+			names = append(names, file.Name())
+		}
+	}
+	sort.Strings(names) // the names are zero padded sequence numbers
+
+	s := &Spool{opts: opts}
+	for _, name := range names {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolFileExt), 10, 64)
+		if err != nil {
+			continue // not written by the spool
+		}
+		raw, reading, err := s.read(name)
+		if err != nil {
+			logrus.Warnf("Removing invalid spooled reading %s: %v", name, err)
+			_ = os.Remove(filepath.Join(opts.Dir, name))
+			continue
+		}
+		s.entries = append(s.entries, spoolEntry{name: name, size: int64(len(raw)), time: reading.Time})
+		s.size += int64(len(raw))
+		s.next = seq + 1
+	}
+	s.limit(time.Now())
+
+	return s, nil
+}
+
+// Push appends the reading to the spool.
+func (s *Spool) Push(reading *Reading) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	raw, err := json.Marshal(reading)
+	if err != nil {
+		return fmt.Errorf("failed to encode reading: %w", err)
+	}
+
+	// write to a temporary file first, so that a crash never leaves a partial reading behind
+	name := fmt.Sprintf("%020d%s", s.next, spoolFileExt)
+	tmp := filepath.Join(s.opts.Dir, name+".tmp")
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("failed to write spooled reading: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.opts.Dir, name)); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write spooled reading: %w", err)
+	}
+
+	s.next++
+	s.entries = append(s.entries, spoolEntry{name: name, size: int64(len(raw)), time: reading.Time})
+	s.size += int64(len(raw))
+	s.limit(time.Now())
+
+	return nil
+}
+
+// Peek returns the oldest reading, nil if the spool is empty.
+func (s *Spool) Peek() (*Reading, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.limit(time.Now())
+	if len(s.entries) == 0 {
+		return nil, nil
+	}
+
+	_, reading, err := s.read(s.entries[0].name)
+	if err != nil {
+		return nil, err
+	}
+
+	return reading, nil
+}
+
+// Pop removes the oldest reading.
+func (s *Spool) Pop() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if len(s.entries) == 0 {
+		return nil
+	}
+
+	return s.remove()
+}
+
+// Len returns the number of spooled readings.
+func (s *Spool) Len() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return len(s.entries)
+}
+
+// limit drops the oldest readings while the spool is too large and the readings that are too old. The caller must
+// hold the lock.
+func (s *Spool) limit(now time.Time) {
+	for len(s.entries) > 0 {
+		oldest := s.entries[0]
+		switch {
+		case s.size > s.opts.MaxSize:
+			logrus.Warnf("Spool %s is full, dropped the reading of %s", s.opts.Dir, oldest.time.Format(time.RFC3339))
+		case s.opts.MaxAge > 0 && now.Sub(oldest.time) > s.opts.MaxAge:
+			logrus.Warnf("Spool %s dropped the expired reading of %s", s.opts.Dir, oldest.time.Format(time.RFC3339))
+		default:
+			return
+		}
+
+		if err := s.remove(); err != nil {
+			logrus.Errorf("Failed to drop spooled reading: %v", err)
+			return
+		}
+	}
+}
+
+// remove deletes the oldest reading, the caller must hold the lock.
+func (s *Spool) remove() error {
+	oldest := s.entries[0]
+	if err := os.Remove(filepath.Join(s.opts.Dir, oldest.name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove spooled reading: %w", err)
+	}
+
+	s.entries = s.entries[1:]
+	s.size -= oldest.size
+
+	return nil
+}
+
+func (s *Spool) read(name string) ([]byte, *Reading, error) {
+	raw, err := os.ReadFile(filepath.Join(s.opts.Dir, name))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read spooled reading: %w", err)
+	}
+
+	reading := &Reading{}
+	if err := json.Unmarshal(raw, reading); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode spooled reading: %w", err)
+	}
+
+	return raw, reading, nil
+}
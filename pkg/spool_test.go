@@ -0,0 +1,145 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func spoolStations(t *testing.T, s *Spool) []string {
+	t.Helper()
+
+	var stations []string
+	for {
+		reading, err := s.Peek()
+		if err != nil {
+			t.Fatalf("Peek() error = %v", err)
+		}
+		if reading == nil {
+			return stations
+		}
+		stations = append(stations, reading.Station)
+		if err := s.Pop(); err != nil {
+			t.Fatalf("Pop() error = %v", err)
+		}
+	}
+}
+
+func TestSpool(t *testing.T) {
+	opts := SpoolOptions{Dir: t.TempDir(), MaxSize: 1 << 20, MaxAge: time.Hour}
+	s, err := OpenSpool(opts)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+
+	now := time.Now().Round(0) // strips the monotonic clock, which is lost by encoding
+	want := &Reading{Time: now, Station: "house", Online: true, Settings: &SettingsData{Units: 1, TimeZone: -2},
+		Channels: []*ChannelData{{Number: 1, Temperature: 21.5, Humidity: 45}}}
+	for _, reading := range []*Reading{want, {Time: now, Station: "garage"}} {
+		if err := s.Push(reading); err != nil {
+			t.Fatalf("Push() error = %v", err)
+		}
+	}
+
+	// a restart keeps the readings and their order
+	if s, err = OpenSpool(opts); err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+	if got := s.Len(); got != 2 {
+		t.Errorf("Len() got = %v, want %v", got, 2)
+	}
+	got, err := s.Peek()
+	if err != nil {
+		t.Fatalf("Peek() error = %v", err)
+	}
+	if !got.Time.Equal(want.Time) {
+		t.Errorf("Peek() time got = %v, want %v", got.Time, want.Time)
+	}
+	got.Time = want.Time // the location differs after decoding
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Peek() got = %+v, want %+v", got, want)
+	}
+	if stations := spoolStations(t, s); !reflect.DeepEqual(stations, []string{"house", "garage"}) {
+		t.Errorf("Peek() got = %v, want %v", stations, []string{"house", "garage"})
+	}
+
+	files, err := os.ReadDir(opts.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("Pop() left %d files", len(files))
+	}
+}
+
+func TestSpool_Limits(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		maxSize  int64
+		maxAge   time.Duration
+		readings []*Reading
+		want     []string
+	}{
+		{
+			name:     "Unlimited",
+			maxSize:  1 << 20,
+			readings: []*Reading{{Time: now, Station: "1"}, {Time: now, Station: "2"}, {Time: now, Station: "3"}},
+			want:     []string{"1", "2", "3"},
+		},
+		{
+			name:     "MaxSize",
+			maxSize:  300, // about two readings
+			readings: []*Reading{{Time: now, Station: "1"}, {Time: now, Station: "2"}, {Time: now, Station: "3"}},
+			want:     []string{"2", "3"},
+		},
+		{
+			name:    "MaxAge",
+			maxSize: 1 << 20,
+			maxAge:  time.Hour,
+			readings: []*Reading{{Time: now.Add(-2 * time.Hour), Station: "1"}, {Time: now, Station: "2"},
+				{Time: now, Station: "3"}},
+			want: []string{"2", "3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := OpenSpool(SpoolOptions{Dir: t.TempDir(), MaxSize: tt.maxSize, MaxAge: tt.maxAge})
+			if err != nil {
+				t.Fatalf("OpenSpool() error = %v", err)
+			}
+			for _, reading := range tt.readings {
+				if err := s.Push(reading); err != nil {
+					t.Fatalf("Push() error = %v", err)
+				}
+			}
+
+			if got := spoolStations(t, s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Peek() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenSpool_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "00000000000000000001.json"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := OpenSpool(SpoolOptions{Dir: dir, MaxSize: 1 << 20})
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+	if got := s.Len(); got != 0 {
+		t.Errorf("Len() got = %v, want %v", got, 0)
+	}
+	if err := s.Push(&Reading{Station: "1"}); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if got := spoolStations(t, s); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Errorf("Peek() got = %v, want %v", got, []string{"1"})
+	}
+}
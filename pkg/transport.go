@@ -0,0 +1,67 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/h44z/dntroomloggpro-go/internal"
+)
+
+// Transport is the connection to a DNT RoomLogg PRO base station.
+// Request sends a single command with its payload and returns the raw response frame
+// (including the message start and end bytes). If noResponse is set, no response is read.
+// Request must return once the context is done, the returned error then wraps the context error.
+type Transport interface {
+	Open() error
+	Close()
+	Request(ctx context.Context, command byte, payload []byte, noResponse ...bool) ([]byte, error)
+}
+
+// tracer is implemented by transports that can record their exchanges.
+type tracer interface {
+	SetTrace(trace *internal.TraceRecorder)
+}
+
+// NewTraceReplay creates a transport that answers requests with the responses of a recorded trace
+// (see RoomLoggConfig.TraceFile). The requests must be sent in the same order as they were recorded.
+func NewTraceReplay(r io.Reader) (Transport, error) {
+	entries, err := internal.ReadTrace(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.NewReplayConnection(entries), nil
+}
+
+// ScriptedTransport is a transport that answers requests with queued responses, for tests of code that uses
+// RoomLogg without a base station. Queue responses with AddResponse, inspect the sent commands with Requests.
+type ScriptedTransport = internal.ScriptedConnection
+
+// ScriptedRequest is a request that was sent to a ScriptedTransport.
+type ScriptedRequest = internal.ScriptedRequest
+
+// NewScriptedTransport creates a transport without queued responses. Requests without a queued response fail with
+// ErrTimeout.
+func NewScriptedTransport() *ScriptedTransport {
+	return internal.NewScriptedConnection()
+}
+
+// openTrace opens the trace file of a station for appending. With multiple stations, the station ID is added to
+// the file name, e.g. trace-house.jsonl.
+func openTrace(path, id string) (*os.File, *internal.TraceRecorder, error) {
+	if id != "" {
+		ext := filepath.Ext(path)
+		path = fmt.Sprintf("%s-%s%s", strings.TrimSuffix(path, ext), id, ext)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open trace file: %w", err)
+	}
+
+	return file, internal.NewTraceRecorder(file, CommandName), nil
+}
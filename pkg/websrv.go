@@ -1,10 +1,15 @@
 package pkg
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -15,14 +20,22 @@ import (
 
 type Server struct {
 	// Core components
-	cfg    *RestConfig
-	server *gin.Engine
+	cfg        *RestConfig
+	server     *gin.Engine
+	httpServer *http.Server
 
+	// cache and direct fetching, per station
+	stations map[string]*stationState
+	serveErr error // why the server stopped listening, nil while it is running
+	mux      sync.RWMutex
+}
+
+type stationState struct {
 	// cache
 	settings *SettingsData
 	channels []*ChannelData
+	derived  []*DerivedData // derived values of the reading, in the order of channels
 	isOnline bool
-	mux      sync.RWMutex
 
 	// direct fetching
 	station *RoomLogg
@@ -41,8 +54,26 @@ func getExecutableDirectory() string {
 	return dir
 }
 
+func init() {
+	RegisterPublisher("rest", PublisherRegistration{
+		Enabled: func(cfg *Config) bool { return cfg.Features.Rest },
+		Config:  func(cfg *Config) any { return cfg.Rest },
+		Queue:   func(cfg *Config) QueueOptions { return cfg.Rest.Queue() },
+		New: func(cfg *Config, stations []*RoomLogg) (Publisher, error) {
+			s, err := NewServer(&cfg.Rest)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range stations {
+				s.SetRoomLogInstance(r)
+			}
+			return s, nil
+		},
+	})
+}
+
 func NewServer(cfg *RestConfig) (*Server, error) {
-	s := &Server{cfg: cfg}
+	s := &Server{cfg: cfg, stations: make(map[string]*stationState)}
 
 	err := s.Setup()
 
@@ -61,85 +92,239 @@ func (s *Server) Setup() error {
 	// Setup http server
 	s.server = gin.Default()
 
-	// Setup all routes
-	s.server.GET("/", s.GetCurrentData)
-	s.server.GET("/calibration", s.GetCalibrationData)
-	s.server.POST("/calibration", s.SetCalibration)
-	s.server.GET("/settings", s.GetSettings)
-	s.server.POST("/settings", s.SetSettings)
-	s.server.GET("/alarm-settings", s.GetAlarmSettings)
-	s.server.POST("/alarm-settings", s.SetAlarmSettings)
-	s.server.GET("/temperature-alarms", s.GetTemperatureAlarms)
-	s.server.POST("/temperature-alarms", s.SetTemperatureAlarms)
-	s.server.GET("/humidity-alarms", s.GetHumidityAlarms)
-	s.server.POST("/humidity-alarms", s.SetHumidityAlarms)
-	s.server.GET("/interval", s.GetIntervalMinutes)
-	s.server.POST("/interval", s.SetIntervalMinutes)
-	s.server.POST("/language", s.SetLanguage)
-	s.server.POST("/time", s.SetCurrentTime)
+	// Setup all routes, the routes of the default station are also available at the root path
+	s.setupStationRoutes(s.server)
+	s.setupStationRoutes(s.server.Group("/stations/:station"))
+	s.server.GET("/stations", s.GetStations)
+	s.server.GET("/metrics", gin.WrapH(DefaultMetrics))
+	s.httpServer = &http.Server{Addr: s.cfg.ListenAddress, Handler: s.server}
 
 	logrus.Infof("[REST] Setup of web service completed!")
 	return nil
 }
 
-func (s *Server) Publish(settings *SettingsData, channels []*ChannelData, isOnline bool) error {
+func (s *Server) setupStationRoutes(r gin.IRoutes) {
+	r.GET("/", s.GetCurrentData)
+	r.GET("/calibration", s.GetCalibrationData)
+	r.POST("/calibration", s.SetCalibration)
+	r.GET("/settings", s.GetSettings)
+	r.POST("/settings", s.SetSettings)
+	r.GET("/alarm-settings", s.GetAlarmSettings)
+	r.POST("/alarm-settings", s.SetAlarmSettings)
+	r.GET("/temperature-alarms", s.GetTemperatureAlarms)
+	r.POST("/temperature-alarms", s.SetTemperatureAlarms)
+	r.GET("/humidity-alarms", s.GetHumidityAlarms)
+	r.POST("/humidity-alarms", s.SetHumidityAlarms)
+	r.GET("/interval", s.GetIntervalMinutes)
+	r.POST("/interval", s.SetIntervalMinutes)
+	r.POST("/language", s.SetLanguage)
+	r.GET("/time", s.GetClockDrift)
+	r.POST("/time", s.SetCurrentTime)
+	r.GET("/history", s.GetSDCardHistory)
+	r.GET("/export", s.GetExportFile)
+}
+
+// Publish caches the reading, it is served until the next reading of the station.
+func (s *Server) Publish(_ context.Context, reading *Reading) error {
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
-	s.settings = settings
-	s.channels = channels
-	s.isOnline = isOnline
+	state := s.getOrCreateState(reading.Station)
+	state.settings = reading.Settings
+	state.channels = reading.Channels
+	state.derived = reading.Derived
+	state.isOnline = reading.Online
 
 	return nil
 }
 
-func (s *Server) Run() {
-	// Run web service
-	err := s.server.Run(s.cfg.ListenAddress)
+// getOrCreateState returns the state of the given station, the caller must hold the write lock.
+func (s *Server) getOrCreateState(station string) *stationState {
+	state, ok := s.stations[station]
+	if !ok {
+		state = &stationState{}
+		s.stations[station] = state
+	}
+	return state
+}
+
+// Start listens on the configured address and serves requests in the background.
+func (s *Server) Start(_ context.Context) error {
+	listener, err := net.Listen("tcp", s.cfg.ListenAddress)
 	if err != nil {
-		logrus.Errorf("[REST] Failed to listen and serve on %s: %v", s.cfg.ListenAddress, err)
+		return fmt.Errorf("failed to listen on %s: %w", s.cfg.ListenAddress, err)
+	}
+
+	logrus.Infof("[REST] Listening on %s", listener.Addr())
+	go func() {
+		err := s.httpServer.Serve(listener)
+		if errors.Is(err, http.ErrServerClosed) {
+			err = errors.New("web service stopped")
+		} else {
+			logrus.Errorf("[REST] Failed to serve on %s: %v", s.cfg.ListenAddress, err)
+		}
+
+		s.mux.Lock()
+		s.serveErr = err
+		s.mux.Unlock()
+	}()
+
+	return nil
+}
+
+// Health returns an error once the server stopped serving requests.
+func (s *Server) Health(_ context.Context) error {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return s.serveErr
+}
+
+// Close shuts the server down, running requests get up to 10 seconds to complete.
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.Shutdown(ctx)
+}
+
+// Shutdown stops listening and waits for running requests until the context is done. Health reports an error once
+// the server stopped listening.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shut down web service: %w", err)
 	}
+
+	logrus.Infof("[REST] Web service on %s stopped", s.cfg.ListenAddress)
+	return nil
+}
+
+func (s *Server) GetStations(c *gin.Context) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	stations := make([]string, 0, len(s.stations))
+	for id := range s.stations {
+		if id != "" {
+			stations = append(stations, id)
+		}
+	}
+	sort.Strings(stations)
+
+	c.JSON(http.StatusOK, stations)
 }
 
 func (s *Server) GetCurrentData(c *gin.Context) {
 	s.mux.RLock()
-	defer s.mux.RLock()
+	defer s.mux.RUnlock()
 
-	if !s.isOnline {
+	state, ok := s.stations[c.Param("station")]
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if !state.isOnline {
 		c.Status(http.StatusGatewayTimeout)
 		return
 	}
 
-	c.JSON(http.StatusOK, s.channels)
+	c.JSON(http.StatusOK, channelResponses(state.channels, state.derived))
+}
+
+// channelResponse is a channel including its derived values.
+type channelResponse struct {
+	*ChannelData
+	*DerivedData
+	Unit string // temperature unit symbol, replaces the numeric unit of the channel data
+}
+
+// channelResponses combines the channels with the derived values of the reading, so that REST serves the same derived
+// values as the other publishers.
+func channelResponses(channels []*ChannelData, derived []*DerivedData) []channelResponse {
+	responses := make([]channelResponse, len(channels))
+	for i, channel := range channels {
+		responses[i] = channelResponse{ChannelData: channel, Unit: channel.Unit.Symbol()}
+		if i < len(derived) {
+			responses[i].DerivedData = derived[i]
+		}
+	}
+
+	return responses
 }
 
 func (s *Server) GetSettings(c *gin.Context) {
 	s.mux.RLock()
-	defer s.mux.RLock()
+	defer s.mux.RUnlock()
 
-	if !s.isOnline {
+	state, ok := s.stations[c.Param("station")]
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if !state.isOnline {
 		c.Status(http.StatusGatewayTimeout)
 		return
 	}
 
-	c.JSON(http.StatusOK, s.settings)
+	c.JSON(http.StatusOK, state.settings)
 }
 
+// SetRoomLogInstance enables direct fetching for the station. The station is identified by its ID.
 func (s *Server) SetRoomLogInstance(station *RoomLogg) {
-	s.station = station
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.getOrCreateState(station.ID()).station = station
+}
+
+// directStation returns the station instance for direct fetching. If the station is unknown or does not support
+// direct fetching, the response status is set and nil is returned.
+func (s *Server) directStation(c *gin.Context) *RoomLogg {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	state, ok := s.stations[c.Param("station")]
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return nil
+	}
+	if state.station == nil {
+		c.Status(http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	return state.station
+}
+
+// errorStatus maps station errors to HTTP status codes.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidValue):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotEnabled):
+		return http.StatusNotImplemented
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrDeviceNotFound), errors.Is(err, ErrDisconnected):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrBadFrame), errors.Is(err, ErrUnexpectedCommand), errors.Is(err, ErrStoreRejected):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
 }
 
 // special functions
 
 func (s *Server) GetCalibrationData(c *gin.Context) {
-	if s.station == nil {
-		c.Status(http.StatusMethodNotAllowed)
+	station := s.directStation(c)
+	if station == nil {
 		return
 	}
 
-	data, err := s.station.FetchCalibrationData()
+	data, err := station.FetchCalibrationDataContext(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -147,29 +332,72 @@ func (s *Server) GetCalibrationData(c *gin.Context) {
 }
 
 func (s *Server) GetIntervalMinutes(c *gin.Context) {
-	if s.station == nil {
-		c.Status(http.StatusMethodNotAllowed)
+	station := s.directStation(c)
+	if station == nil {
 		return
 	}
 
-	data, err := s.station.FetchIntervalMinutes()
+	data, err := station.FetchIntervalMinutesContext(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, data)
 }
 
+func (s *Server) GetSDCardHistory(c *gin.Context) {
+	station := s.directStation(c)
+	if station == nil {
+		return
+	}
+
+	data, err := station.FetchSDCardHistoryContext(c.Request.Context())
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, data)
+}
+
+// GetExportFile returns the station export file, either as JSON (default) or as CSV (?format=csv).
+func (s *Server) GetExportFile(c *gin.Context) {
+	station := s.directStation(c)
+	if station == nil {
+		return
+	}
+
+	data, err := station.FetchExportFileContext(c.Request.Context())
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "csv":
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", "attachment; filename=roomlogg-export.csv")
+		c.Status(http.StatusOK)
+		if err := data.WriteCSV(c.Writer); err != nil {
+			logrus.Errorf("[REST] Failed to write export csv: %v", err)
+		}
+	case "json":
+		c.JSON(http.StatusOK, data)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format"})
+	}
+}
+
 func (s *Server) GetAlarmSettings(c *gin.Context) {
-	if s.station == nil {
-		c.Status(http.StatusMethodNotAllowed)
+	station := s.directStation(c)
+	if station == nil {
 		return
 	}
 
-	data, err := s.station.FetchAlarmSettings()
+	data, err := station.FetchAlarmSettingsContext(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -177,14 +405,14 @@ func (s *Server) GetAlarmSettings(c *gin.Context) {
 }
 
 func (s *Server) GetTemperatureAlarms(c *gin.Context) {
-	if s.station == nil {
-		c.Status(http.StatusMethodNotAllowed)
+	station := s.directStation(c)
+	if station == nil {
 		return
 	}
 
-	data, err := s.station.FetchTemperatureAlarms()
+	data, err := station.FetchTemperatureAlarmsContext(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -192,14 +420,14 @@ func (s *Server) GetTemperatureAlarms(c *gin.Context) {
 }
 
 func (s *Server) GetHumidityAlarms(c *gin.Context) {
-	if s.station == nil {
-		c.Status(http.StatusMethodNotAllowed)
+	station := s.directStation(c)
+	if station == nil {
 		return
 	}
 
-	data, err := s.station.FetchHumidityAlarms()
+	data, err := station.FetchHumidityAlarmsContext(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -207,8 +435,8 @@ func (s *Server) GetHumidityAlarms(c *gin.Context) {
 }
 
 func (s *Server) SetLanguage(c *gin.Context) {
-	if s.station == nil {
-		c.Status(http.StatusMethodNotAllowed)
+	station := s.directStation(c)
+	if station == nil {
 		return
 	}
 
@@ -218,9 +446,9 @@ func (s *Server) SetLanguage(c *gin.Context) {
 		return
 	}
 
-	err := s.station.SetLanguage(input)
+	err := station.SetLanguageContext(c.Request.Context(), input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -228,8 +456,8 @@ func (s *Server) SetLanguage(c *gin.Context) {
 }
 
 func (s *Server) SetIntervalMinutes(c *gin.Context) {
-	if s.station == nil {
-		c.Status(http.StatusMethodNotAllowed)
+	station := s.directStation(c)
+	if station == nil {
 		return
 	}
 
@@ -239,9 +467,9 @@ func (s *Server) SetIntervalMinutes(c *gin.Context) {
 		return
 	}
 
-	err := s.station.SetIntervalMinutes(input)
+	err := station.SetIntervalMinutesContext(c.Request.Context(), input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -249,8 +477,8 @@ func (s *Server) SetIntervalMinutes(c *gin.Context) {
 }
 
 func (s *Server) SetCalibration(c *gin.Context) {
-	if s.station == nil {
-		c.Status(http.StatusMethodNotAllowed)
+	station := s.directStation(c)
+	if station == nil {
 		return
 	}
 
@@ -260,9 +488,9 @@ func (s *Server) SetCalibration(c *gin.Context) {
 		return
 	}
 
-	err := s.station.SetCalibrationData(input)
+	err := station.SetCalibrationDataContext(c.Request.Context(), input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -270,8 +498,8 @@ func (s *Server) SetCalibration(c *gin.Context) {
 }
 
 func (s *Server) SetSettings(c *gin.Context) {
-	if s.station == nil {
-		c.Status(http.StatusMethodNotAllowed)
+	station := s.directStation(c)
+	if station == nil {
 		return
 	}
 
@@ -281,9 +509,9 @@ func (s *Server) SetSettings(c *gin.Context) {
 		return
 	}
 
-	err := s.station.SetSettings(input)
+	err := station.SetSettingsContext(c.Request.Context(), input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -291,8 +519,8 @@ func (s *Server) SetSettings(c *gin.Context) {
 }
 
 func (s *Server) SetAlarmSettings(c *gin.Context) {
-	if s.station == nil {
-		c.Status(http.StatusMethodNotAllowed)
+	station := s.directStation(c)
+	if station == nil {
 		return
 	}
 
@@ -302,9 +530,9 @@ func (s *Server) SetAlarmSettings(c *gin.Context) {
 		return
 	}
 
-	err := s.station.SetAlarmSettings(input)
+	err := station.SetAlarmSettingsContext(c.Request.Context(), input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -312,8 +540,8 @@ func (s *Server) SetAlarmSettings(c *gin.Context) {
 }
 
 func (s *Server) SetTemperatureAlarms(c *gin.Context) {
-	if s.station == nil {
-		c.Status(http.StatusMethodNotAllowed)
+	station := s.directStation(c)
+	if station == nil {
 		return
 	}
 
@@ -323,9 +551,9 @@ func (s *Server) SetTemperatureAlarms(c *gin.Context) {
 		return
 	}
 
-	err := s.station.SetTemperatureAlarms(input)
+	err := station.SetTemperatureAlarmsContext(c.Request.Context(), input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -333,8 +561,8 @@ func (s *Server) SetTemperatureAlarms(c *gin.Context) {
 }
 
 func (s *Server) SetHumidityAlarms(c *gin.Context) {
-	if s.station == nil {
-		c.Status(http.StatusMethodNotAllowed)
+	station := s.directStation(c)
+	if station == nil {
 		return
 	}
 
@@ -344,24 +572,39 @@ func (s *Server) SetHumidityAlarms(c *gin.Context) {
 		return
 	}
 
-	err := s.station.SetHumidityAlarms(input)
+	err := station.SetHumidityAlarmsContext(c.Request.Context(), input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"status": true})
 }
 
+func (s *Server) GetClockDrift(c *gin.Context) {
+	station := s.directStation(c)
+	if station == nil {
+		return
+	}
+
+	data, err := station.FetchClockDriftContext(c.Request.Context())
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, data)
+}
+
 func (s *Server) SetCurrentTime(c *gin.Context) {
-	if s.station == nil {
-		c.Status(http.StatusMethodNotAllowed)
+	station := s.directStation(c)
+	if station == nil {
 		return
 	}
 
-	err := s.station.SetTime(TimeData{time: time.Now()})
+	err := station.SyncTimeContext(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
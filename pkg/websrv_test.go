@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_errorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"invalid value", fmt.Errorf("%w: interval", ErrInvalidValue), http.StatusBadRequest},
+		{"timeout", newRequestError(CommandGetSettings, ErrTimeout), http.StatusGatewayTimeout},
+		{"deadline", newRequestError(CommandGetSettings, context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{"disconnected", newRequestError(CommandGetSettings, ErrDisconnected), http.StatusServiceUnavailable},
+		{"not found", ErrDeviceNotFound, http.StatusServiceUnavailable},
+		{"bad frame", newRequestError(CommandGetSettings, ErrBadFrame), http.StatusBadGateway},
+		{"store rejected", newRequestError(CommandStartStore, ErrStoreRejected), http.StatusBadGateway},
+		{"unknown", errors.New("unknown"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorStatus(tt.err); got != tt.want {
+				t.Errorf("errorStatus() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_channelResponses(t *testing.T) {
+	channels := []*ChannelData{
+		{Number: 1, Temperature: 20, Humidity: 50},
+		{Number: 2, Temperature: 20, Humidity: 50, Info: &ChannelInfo{Name: "Garden", Outdoor: true}},
+	}
+	reading := NewReading(time.Now(), "", nil, channels, true)
+
+	got, err := json.Marshal(channelResponses(reading.Channels, reading.Derived))
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	want := `[{"Number":1,"Temperature":20,"Humidity":50,"Info":null,` +
+		`"DewPoint":9.3,"HeatIndex":20,"AbsoluteHumidity":8.6,"VapourPressure":11.7,"Unit":"°C"},` +
+		`{"Number":2,"Temperature":20,"Humidity":50,"Info":{"Name":"Garden","Room":"","Floor":"","Outdoor":true},` +
+		`"DewPoint":9.3,"HeatIndex":20,"AbsoluteHumidity":8.6,"VapourPressure":11.7,"Unit":"°C"}]`
+	if string(got) != want {
+		t.Errorf("channelResponses() got = %s, want %s", got, want)
+	}
+
+	// The derived values of the reading are served as they are, not computed again
+	reading.Derived[0] = &DerivedData{DewPoint: 1}
+	if responses := channelResponses(reading.Channels, reading.Derived); responses[0].DewPoint != 1 {
+		t.Errorf("channelResponses() dew point = %v, want %v", responses[0].DewPoint, 1)
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	s, err := NewServer(&RestConfig{ListenAddress: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	ctx := context.Background()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Health(ctx) == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := s.Health(ctx); err == nil {
+		t.Errorf("Health() after Shutdown() error = nil, want an error")
+	}
+}
+
+func TestServer_Lifecycle(t *testing.T) {
+	s, err := NewServer(&RestConfig{ListenAddress: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	ctx := context.Background()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := s.Health(ctx); err != nil {
+		t.Errorf("Health() error = %v, want nil", err)
+	}
+
+	reading := NewReading(time.Now(), "garage", nil, []*ChannelData{{Number: 1}}, true)
+	if err := s.Publish(ctx, reading); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if state := s.stations["garage"]; state == nil || !reflect.DeepEqual(state.channels, reading.Channels) {
+		t.Errorf("Publish() cached = %v, want %v", state, reading.Channels)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Health(ctx) == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := s.Health(ctx); err == nil {
+		t.Errorf("Health() after Close() error = nil, want an error")
+	}
+}